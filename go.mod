@@ -4,10 +4,15 @@ go 1.25.1
 
 require (
 	github.com/peterbourgon/ff/v4 v4.0.0-beta.1
+	golang.org/x/net v0.44.0
+	golang.org/x/term v0.35.0
 	software.sslmate.com/src/go-pkcs12 v0.6.0
 )
 
-require golang.org/x/crypto v0.42.0 // indirect
+require (
+	golang.org/x/crypto v0.42.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+)
 
 replace github.com/gregtwallace/brother-cert/cmd/brother-cert => /pkg/cmd/brother-cert
 
@@ -3,8 +3,11 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/peterbourgon/ff/v4"
 	"github.com/peterbourgon/ff/v4/ffhelp"
@@ -20,6 +23,24 @@ type app struct {
 	config    *config
 }
 
+// exitCodeError is returned by commands that need the app to exit with a
+// specific exit code. If err is nil, nothing is logged.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 // actual application start
 func Start() {
 	// make app w/ logger
@@ -28,12 +49,12 @@ func Start() {
 		errLogger: log.New(os.Stderr, "", 0),
 	}
 
-	// log start
-	app.stdLogger.Printf("brother-cert v%s", appVersion)
-
 	// get & parse config
 	err := app.getConfig()
 
+	// log start
+	app.stdLogger.Printf("brother-cert v%s", appVersion)
+
 	// deal with config err (after logger re-init)
 	if err != nil {
 		exitCode := 0
@@ -60,12 +81,23 @@ func Start() {
 		os.Exit(exitCode)
 	}
 
-	// run it
+	// run it (long running commands stop on interrupt)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	exitCode := 0
-	err = app.cmd.Run(context.Background())
+	err = app.cmd.Run(ctx)
+	stop()
 	if err != nil {
-		exitCode = 1
-		app.errLogger.Print(err)
+		exitCode = exitCodeOf(err)
+
+		var codeErr *exitCodeError
+		if errors.As(err, &codeErr) {
+			exitCode = codeErr.code
+			if codeErr.err != nil {
+				app.errLogger.Print(codeErr.err)
+			}
+		} else {
+			app.errLogger.Print(err)
+		}
 
 		// if extra args, show help
 		if errors.Is(err, ErrExtraArgs) {
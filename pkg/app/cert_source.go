@@ -0,0 +1,255 @@
+package app
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// certSource is a source of the key and cert pem to install on the printer
+type certSource interface {
+	// name is the name of the source for messages
+	name() string
+	// configured returns true if any option for the source was specified
+	configured() bool
+	// getPemBytes returns the key and cert pem for the printer hostname from
+	// the source
+	getPemBytes(subcommand, hostname string) (keyPem, certPem []byte, err error)
+}
+
+// certIssuer is a certSource that issues a new cert each time it is used (so
+// the new cert is never the one already installed)
+type certIssuer interface {
+	certSource
+	// needsNewCert returns true if the printer's (hostname) current cert (nil
+	// if unknown) wasn't issued by the source for hostname or is due for
+	// renewal
+	needsNewCert(subcommand, hostname string, current *x509.Certificate) (bool, error)
+}
+
+// sources returns all of the cert sources
+func (kcCfg *keyCertPemCfg) sources() []certSource {
+	return []certSource{
+		&kcCfg.pemFileCfg,
+		&kcCfg.certWarden,
+		&kcCfg.vault,
+		&kcCfg.urlSource,
+	}
+}
+
+// anyConfigured returns true if any cert source was configured
+func (kcCfg *keyCertPemCfg) anyConfigured() bool {
+	for _, source := range kcCfg.sources() {
+		if source.configured() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// configuredSource returns the configured cert source, or an error if more
+// than one is configured. If none is, pem/file is returned so the error it
+// returns explains what's missing.
+func (kcCfg *keyCertPemCfg) configuredSource(subcommand string) (certSource, error) {
+	// exactly one source must be configured
+	configured := []certSource{}
+	names := []string{}
+	for _, source := range kcCfg.sources() {
+		if source.configured() {
+			configured = append(configured, source)
+			names = append(names, source.name())
+		}
+	}
+
+	if len(configured) > 1 {
+		return nil, fmt.Errorf("%s: failed, more than one key/cert source specified (%s)", subcommand, strings.Join(names, ", "))
+	}
+
+	if len(configured) == 0 {
+		return &kcCfg.pemFileCfg, nil
+	}
+
+	return configured[0], nil
+}
+
+// GetPemBytes returns the key and cert pem bytes for the printer hostname as
+// specified in keyCertPemCfg or an error if it cant get the bytes of both
+func (kcCfg *keyCertPemCfg) GetPemBytes(subcommand, hostname string) (keyPem, certPem []byte, err error) {
+	source, err := kcCfg.configuredSource(subcommand)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source.getPemBytes(subcommand, hostname)
+}
+
+// issuesCerts returns true if the configured source issues a new cert each
+// time it is used
+func (kcCfg *keyCertPemCfg) issuesCerts() bool {
+	source, err := kcCfg.configuredSource("")
+	if err != nil {
+		return false
+	}
+
+	_, issues := source.(certIssuer)
+	return issues
+}
+
+// needsNewCert returns true if the configured source should be used for the
+// printer (hostname) whose current cert is current (nil if unknown). Only
+// sources that issue certs ever return false, when current is one they issued
+// that isn't due for renewal (the others are checked by comparing the certs).
+func (kcCfg *keyCertPemCfg) needsNewCert(subcommand, hostname string, current *x509.Certificate) (bool, error) {
+	source, err := kcCfg.configuredSource(subcommand)
+	if err != nil {
+		return false, err
+	}
+
+	issuer, issues := source.(certIssuer)
+	if !issues {
+		return true, nil
+	}
+
+	return issuer.needsNewCert(subcommand, hostname, current)
+}
+
+// sourceHttpClient is the client for cert sources that download over http(s)
+var sourceHttpClient = &http.Client{
+	Timeout:       30 * time.Second,
+	CheckRedirect: sameOriginRedirect,
+}
+
+// sameOriginRedirect only allows redirects to the same scheme and host as the
+// original request, since the sources' credentials (e.g. a bearer token or an
+// api key header) are sent again and the key must never be fetched over http
+func sameOriginRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	orig := via[0].URL
+	if !strings.EqualFold(req.URL.Scheme, orig.Scheme) || !strings.EqualFold(req.URL.Host, orig.Host) {
+		return fmt.Errorf("refusing redirect from %s://%s to %s://%s", orig.Scheme, orig.Host, req.URL.Scheme, req.URL.Host)
+	}
+
+	return nil
+}
+
+// fetch does req and returns the response body. It returns an error if the
+// request fails or the response status isn't OK.
+func fetch(req *http.Request) ([]byte, error) {
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := sourceHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	return bodyBytes, nil
+}
+
+// fetchPem is fetch, but also returns an error if the response body isn't
+// pem (e.g. an html error or login page)
+func fetchPem(req *http.Request) ([]byte, error) {
+	pemBytes, err := fetch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("response isn't pem")
+	}
+
+	return pemBytes, nil
+}
+
+// pemFileCfg contains the values for the key and cert pem when specified
+// directly as strings or as files
+type pemFileCfg struct {
+	keyPemFilePath  *string
+	certPemFilePath *string
+	keyPem          *string
+	certPem         *string
+}
+
+func (pfCfg *pemFileCfg) name() string {
+	return "pem/file"
+}
+
+func (pfCfg *pemFileCfg) configured() bool {
+	for _, val := range []*string{pfCfg.keyPemFilePath, pfCfg.certPemFilePath, pfCfg.keyPem, pfCfg.certPem} {
+		if val != nil && *val != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getPemBytes returns the key and cert from the pem strings and/or files
+func (pfCfg *pemFileCfg) getPemBytes(subcommand, _ string) (keyPem, certPem []byte, err error) {
+	// key pem (from arg or file)
+	if pfCfg.keyPem != nil && *pfCfg.keyPem != "" {
+		// error if filename is also set
+		if pfCfg.keyPemFilePath != nil && *pfCfg.keyPemFilePath != "" {
+			return nil, nil, fmt.Errorf("%s: failed, both key pem and key file specified", subcommand)
+		}
+
+		// use pem
+		keyPem = []byte(*pfCfg.keyPem)
+	} else {
+		// pem wasn't specified, try reading file
+		if pfCfg.keyPemFilePath == nil || *pfCfg.keyPemFilePath == "" {
+			return nil, nil, fmt.Errorf("%s: failed, neither key pem nor key file specified", subcommand)
+		}
+
+		// read file to get pem
+		keyPem, err = os.ReadFile(*pfCfg.keyPemFilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: failed to read key file (%w)", subcommand, err)
+		}
+	}
+
+	// cert pem (repeat same process)
+	if pfCfg.certPem != nil && *pfCfg.certPem != "" {
+		// error if filename is also set
+		if pfCfg.certPemFilePath != nil && *pfCfg.certPemFilePath != "" {
+			return nil, nil, fmt.Errorf("%s: failed, both cert pem and cert file specified", subcommand)
+		}
+
+		// use pem
+		certPem = []byte(*pfCfg.certPem)
+	} else {
+		// pem wasn't specified, try reading file
+		if pfCfg.certPemFilePath == nil || *pfCfg.certPemFilePath == "" {
+			return nil, nil, fmt.Errorf("%s: failed, neither cert pem nor cert file specified", subcommand)
+		}
+
+		// read file to get pem
+		certPem, err = os.ReadFile(*pfCfg.certPemFilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: failed to read cert file (%w)", subcommand, err)
+		}
+	}
+
+	return keyPem, certPem, nil
+}
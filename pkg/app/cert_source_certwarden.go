@@ -0,0 +1,81 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	certWardenKeyPath  = "/certwarden/api/v1/download/privatekeys/"
+	certWardenCertPath = "/certwarden/api/v1/download/certificates/"
+)
+
+// certWardenCfg contains the values to fetch the key and cert pem from a
+// Cert Warden server's download api
+type certWardenCfg struct {
+	baseUrl    *string
+	keyName    *string
+	keyApiKey  *string
+	certName   *string
+	certApiKey *string
+}
+
+func (cwCfg *certWardenCfg) name() string {
+	return "cert warden"
+}
+
+func (cwCfg *certWardenCfg) configured() bool {
+	for _, val := range []*string{cwCfg.baseUrl, cwCfg.keyName, cwCfg.keyApiKey, cwCfg.certName, cwCfg.certApiKey} {
+		if val != nil && *val != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// download fetches the pem at path + name from the Cert Warden server using
+// apiKey
+func (cwCfg *certWardenCfg) download(subcommand, path, name, apiKey string) ([]byte, error) {
+	u, err := url.ParseRequestURI(strings.TrimSuffix(*cwCfg.baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid cert warden url (%w)", subcommand, err)
+	}
+	u = u.JoinPath(path, url.PathEscape(name))
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", apiKey)
+
+	pemBytes, err := fetchPem(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cert warden download of '%s' failed (%w)", subcommand, name, err)
+	}
+
+	return pemBytes, nil
+}
+
+// getPemBytes downloads the key and cert pem from Cert Warden
+func (cwCfg *certWardenCfg) getPemBytes(subcommand, _ string) (keyPem, certPem []byte, err error) {
+	// all values required
+	if *cwCfg.baseUrl == "" || *cwCfg.keyName == "" || *cwCfg.keyApiKey == "" || *cwCfg.certName == "" || *cwCfg.certApiKey == "" {
+		return nil, nil, errors.New(subcommand + ": failed, cert warden url, key name, key api key, cert name, and cert api key must all be specified")
+	}
+
+	keyPem, err = cwCfg.download(subcommand, certWardenKeyPath, *cwCfg.keyName, *cwCfg.keyApiKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPem, err = cwCfg.download(subcommand, certWardenCertPath, *cwCfg.certName, *cwCfg.certApiKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keyPem, certPem, nil
+}
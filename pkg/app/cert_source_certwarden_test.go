@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertWardenGetPemBytes(t *testing.T) {
+	ca := newTestCert(t, "Test Root CA", time.Now().Add(24*time.Hour), nil)
+	leaf := newTestCert(t, "printer.example.com", time.Now().Add(time.Hour), ca)
+
+	tests := []struct {
+		name       string
+		certApiKey string
+		certBody   []byte
+		wantErr    string
+	}{
+		{
+			name:       "success",
+			certApiKey: "cert-key",
+			certBody:   leaf.certPem,
+		},
+		{
+			name:       "bad api key",
+			certApiKey: "wrong-key",
+			certBody:   leaf.certPem,
+			wantErr:    "status code 401",
+		},
+		{
+			name:       "response isn't pem",
+			certApiKey: "cert-key",
+			certBody:   []byte("<html><body>Cert Warden</body></html>"),
+			wantErr:    "response isn't pem",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var apiKey string
+				var body []byte
+				switch r.URL.Path {
+				case certWardenKeyPath + "printer key":
+					apiKey, body = "key-key", leaf.keyPem
+				case certWardenCertPath + "printer":
+					apiKey, body = "cert-key", tt.certBody
+				default:
+					http.NotFound(w, r)
+					return
+				}
+
+				if r.Header.Get("X-API-Key") != apiKey {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				_, _ = w.Write(body)
+			}))
+			defer srv.Close()
+
+			baseUrl, keyName, keyApiKey, certName := srv.URL+"/", "printer key", "key-key", "printer"
+			cwCfg := &certWardenCfg{
+				baseUrl:    &baseUrl,
+				keyName:    &keyName,
+				keyApiKey:  &keyApiKey,
+				certName:   &certName,
+				certApiKey: &tt.certApiKey,
+			}
+
+			keyPem, certPem, err := cwCfg.getPemBytes("test", "printer.example.com")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error is %v, want one containing '%s'", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			if !bytes.Equal(keyPem, leaf.keyPem) {
+				t.Errorf("key pem isn't the served key")
+			}
+			if !bytes.Equal(certPem, leaf.certPem) {
+				t.Errorf("cert pem isn't the served cert")
+			}
+		})
+	}
+}
+
+func TestCertWardenRedirectToOtherHost(t *testing.T) {
+	leaked := false
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "" {
+			leaked = true
+		}
+	}))
+	defer other.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	baseUrl, keyName, keyApiKey, certName, certApiKey := srv.URL, "printer", "key-key", "printer", "cert-key"
+	cwCfg := &certWardenCfg{
+		baseUrl:    &baseUrl,
+		keyName:    &keyName,
+		keyApiKey:  &keyApiKey,
+		certName:   &certName,
+		certApiKey: &certApiKey,
+	}
+
+	_, _, err := cwCfg.getPemBytes("test", "printer.example.com")
+	if err == nil || !strings.Contains(err.Error(), "refusing redirect") {
+		t.Fatalf("error is %v, want a refused redirect", err)
+	}
+	if leaked {
+		t.Errorf("api key was sent to the other host")
+	}
+}
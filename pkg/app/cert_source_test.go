@@ -0,0 +1,81 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testCert is a key and cert for cert source tests
+type testCert struct {
+	cert    *x509.Certificate
+	key     *rsa.PrivateKey
+	keyPem  []byte
+	certPem []byte
+}
+
+// newTestCert returns a new key and cert for commonName that expires at
+// notAfter, signed by issuer (or a self-signed CA if issuer is nil)
+func newTestCert(t *testing.T, commonName string, notAfter time.Time, issuer *testCert) *testCert {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key (%s)", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("failed to generate serial (%s)", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	parent, signer := template, key
+	if issuer == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.DNSNames = []string{commonName}
+		template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		parent, signer = issuer.cert, issuer.key
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("failed to create cert (%s)", err)
+	}
+
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("failed to parse cert (%s)", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		keyPem:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		certPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}),
+	}
+}
+
+// certOf returns tc's cert, or nil if tc is nil
+func certOf(tc *testCert) *x509.Certificate {
+	if tc == nil {
+		return nil
+	}
+
+	return tc.cert
+}
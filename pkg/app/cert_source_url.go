@@ -0,0 +1,78 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// urlSourceCfg contains the values to download the key and cert pem from
+// generic https urls
+type urlSourceCfg struct {
+	keyUrl  *string
+	certUrl *string
+	token   *string
+}
+
+func (uCfg *urlSourceCfg) name() string {
+	return "url"
+}
+
+func (uCfg *urlSourceCfg) configured() bool {
+	for _, val := range []*string{uCfg.keyUrl, uCfg.certUrl, uCfg.token} {
+		if val != nil && *val != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// download fetches the pem at rawUrl
+func (uCfg *urlSourceCfg) download(subcommand, rawUrl string) ([]byte, error) {
+	u, err := url.ParseRequestURI(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid url '%s' (%w)", subcommand, rawUrl, err)
+	}
+
+	// never send the token or receive the key in the clear
+	if !strings.EqualFold(u.Scheme, "https") {
+		return nil, fmt.Errorf("%s: url '%s' must use https", subcommand, rawUrl)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if *uCfg.token != "" {
+		req.Header.Set("Authorization", "Bearer "+*uCfg.token)
+	}
+
+	pemBytes, err := fetchPem(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: download of '%s' failed (%w)", subcommand, rawUrl, err)
+	}
+
+	return pemBytes, nil
+}
+
+// getPemBytes downloads the key and cert pem from their urls
+func (uCfg *urlSourceCfg) getPemBytes(subcommand, _ string) (keyPem, certPem []byte, err error) {
+	if *uCfg.keyUrl == "" || *uCfg.certUrl == "" {
+		return nil, nil, errors.New(subcommand + ": failed, key url and cert url must both be specified")
+	}
+
+	keyPem, err = uCfg.download(subcommand, *uCfg.keyUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPem, err = uCfg.download(subcommand, *uCfg.certUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keyPem, certPem, nil
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUrlSourceGetPemBytes(t *testing.T) {
+	ca := newTestCert(t, "Test Root CA", time.Now().Add(24*time.Hour), nil)
+	leaf := newTestCert(t, "printer.example.com", time.Now().Add(time.Hour), ca)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer url-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/printer/key.pem":
+			_, _ = w.Write(leaf.keyPem)
+		case "/printer/cert.pem":
+			_, _ = w.Write(leaf.certPem)
+		case "/printer/moved.pem":
+			http.Redirect(w, r, "/printer/cert.pem", http.StatusFound)
+		case "/printer/to-http.pem":
+			http.Redirect(w, r, "http://"+r.Host+"/printer/cert.pem", http.StatusFound)
+		case "/printer/to-other-host.pem":
+			http.Redirect(w, r, "https://other.example.com/printer/cert.pem", http.StatusFound)
+		case "/printer/error.html":
+			_, _ = w.Write([]byte("<html><body>Sign in</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	// trust the stub server's cert
+	defaultClient := sourceHttpClient
+	sourceHttpClient = srv.Client()
+	sourceHttpClient.CheckRedirect = defaultClient.CheckRedirect
+	defer func() { sourceHttpClient = defaultClient }()
+
+	tests := []struct {
+		name    string
+		keyUrl  string
+		certUrl string
+		token   string
+		wantErr string
+	}{
+		{
+			name:    "success",
+			keyUrl:  srv.URL + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/cert.pem",
+			token:   "url-token",
+		},
+		{
+			name:    "same origin redirect",
+			keyUrl:  srv.URL + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/moved.pem",
+			token:   "url-token",
+		},
+		{
+			name:    "redirect to http",
+			keyUrl:  srv.URL + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/to-http.pem",
+			token:   "url-token",
+			wantErr: "refusing redirect",
+		},
+		{
+			name:    "redirect to another host",
+			keyUrl:  srv.URL + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/to-other-host.pem",
+			token:   "url-token",
+			wantErr: "refusing redirect",
+		},
+		{
+			name:    "bad token",
+			keyUrl:  srv.URL + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/cert.pem",
+			token:   "wrong-token",
+			wantErr: "status code 401",
+		},
+		{
+			name:    "response isn't pem",
+			keyUrl:  srv.URL + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/error.html",
+			token:   "url-token",
+			wantErr: "response isn't pem",
+		},
+		{
+			name:    "not found",
+			keyUrl:  srv.URL + "/printer/missing.pem",
+			certUrl: srv.URL + "/printer/cert.pem",
+			token:   "url-token",
+			wantErr: "status code 404",
+		},
+		{
+			name:    "plain http",
+			keyUrl:  strings.Replace(srv.URL, "https://", "http://", 1) + "/printer/key.pem",
+			certUrl: srv.URL + "/printer/cert.pem",
+			token:   "url-token",
+			wantErr: "must use https",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uCfg := &urlSourceCfg{
+				keyUrl:  &tt.keyUrl,
+				certUrl: &tt.certUrl,
+				token:   &tt.token,
+			}
+
+			keyPem, certPem, err := uCfg.getPemBytes("test", "printer.example.com")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error is %v, want one containing '%s'", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			if !bytes.Equal(keyPem, leaf.keyPem) {
+				t.Errorf("key pem isn't the served key")
+			}
+			if !bytes.Equal(certPem, leaf.certPem) {
+				t.Errorf("cert pem isn't the served cert")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vaultCfg contains the values to issue a new key and cert from a HashiCorp
+// Vault PKI secrets engine
+type vaultCfg struct {
+	addr  *string
+	token *string
+	mount *string
+	role  *string
+	ttl   *string
+	// renewBefore is how long before the current cert expires a new one is
+	// issued
+	renewBefore *time.Duration
+}
+
+func (vCfg *vaultCfg) name() string {
+	return "vault"
+}
+
+// configured ignores mount since it has a default value
+func (vCfg *vaultCfg) configured() bool {
+	for _, val := range []*string{vCfg.addr, vCfg.token, vCfg.role, vCfg.ttl} {
+		if val != nil && *val != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apiUrl returns the url of the vault api path elem (under v1)
+func (vCfg *vaultCfg) apiUrl(subcommand string, elem ...string) (*url.URL, error) {
+	// required values
+	if *vCfg.addr == "" || *vCfg.token == "" || *vCfg.mount == "" || *vCfg.role == "" {
+		return nil, errors.New(subcommand + ": failed, vault addr, token, pki mount, and role must all be specified")
+	}
+
+	u, err := url.ParseRequestURI(strings.TrimSuffix(*vCfg.addr, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid vault addr (%w)", subcommand, err)
+	}
+
+	return u.JoinPath(append([]string{"v1", strings.Trim(*vCfg.mount, "/")}, elem...)...), nil
+}
+
+// needsNewCert returns true unless current was issued by the pki mount's CA
+// for hostname and doesn't expire within renewBefore
+func (vCfg *vaultCfg) needsNewCert(subcommand, hostname string, current *x509.Certificate) (bool, error) {
+	if current == nil || time.Until(current.NotAfter) <= *vCfg.renewBefore {
+		return true, nil
+	}
+
+	// e.g. GET https://vault.example.com:8200/v1/pki/ca/pem
+	u, err := vCfg.apiUrl(subcommand, "ca", "pem")
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	caPem, err := fetch(req)
+	if err != nil {
+		return false, fmt.Errorf("%s: vault ca download failed (%w)", subcommand, err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPem) {
+		return false, fmt.Errorf("%s: vault ca isn't a pem certificate", subcommand)
+	}
+
+	_, err = current.Verify(x509.VerifyOptions{
+		DNSName: hostname,
+		Roots:   roots,
+	})
+	return err != nil, nil
+}
+
+// getPemBytes issues a new key and cert for the printer hostname from vault
+func (vCfg *vaultCfg) getPemBytes(subcommand, hostname string) (keyPem, certPem []byte, err error) {
+	if hostname == "" {
+		return nil, nil, errors.New(subcommand + ": failed, vault requires the printer hostname (to use as the common name)")
+	}
+
+	// e.g. POST https://vault.example.com:8200/v1/pki/issue/printers
+	u, err := vCfg.apiUrl(subcommand, "issue", url.PathEscape(*vCfg.role))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := map[string]string{
+		"common_name":        hostname,
+		"format":             "pem",
+		"private_key_format": "pem",
+	}
+	if *vCfg.ttl != "" {
+		payload["ttl"] = *vCfg.ttl
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", *vCfg.token)
+
+	bodyBytes, err := fetch(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: vault issue failed (%w)", subcommand, err)
+	}
+
+	// decode response
+	response := struct {
+		Data struct {
+			Certificate    string `json:"certificate"`
+			IssuingCA      string `json:"issuing_ca"`
+			PrivateKey     string `json:"private_key"`
+			PrivateKeyType string `json:"private_key_type"`
+		} `json:"data"`
+	}{}
+
+	err = json.Unmarshal(bodyBytes, &response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: failed to decode vault response (%w)", subcommand, err)
+	}
+
+	if response.Data.PrivateKey == "" || response.Data.Certificate == "" {
+		return nil, nil, fmt.Errorf("%s: vault response is missing the key and/or cert", subcommand)
+	}
+
+	// printer only supports rsa
+	if response.Data.PrivateKeyType != "rsa" {
+		return nil, nil, fmt.Errorf("%s: vault issued a '%s' key, the vault role must be configured to issue rsa keys", subcommand, response.Data.PrivateKeyType)
+	}
+
+	certPem = []byte(strings.TrimSpace(response.Data.Certificate) + "\n")
+
+	// include the issuing ca as the chain cert, unless it's the root (which
+	// is installed separately and would only use up storage on the printer)
+	issuingCAPemBlock, _ := pem.Decode([]byte(response.Data.IssuingCA))
+	if issuingCAPemBlock != nil {
+		issuingCA, err := x509.ParseCertificate(issuingCAPemBlock.Bytes)
+		if err == nil && !bytes.Equal(issuingCA.RawSubject, issuingCA.RawIssuer) {
+			certPem = append(certPem, []byte(strings.TrimSpace(response.Data.IssuingCA)+"\n")...)
+		}
+	}
+
+	return []byte(response.Data.PrivateKey), certPem, nil
+}
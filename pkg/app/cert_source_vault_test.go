@@ -0,0 +1,183 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestVault returns a stub vault server whose pki mount issues leaf for
+// role (with a keyType key) and serves ca as its CA, and a vaultCfg for it
+func newTestVault(t *testing.T, ca, leaf *testCert, keyType string) (*httptest.Server, *vaultCfg) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/pki/ca/pem":
+			_, _ = w.Write(ca.certPem)
+
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/pki/issue/printers":
+			if r.Header.Get("X-Vault-Token") != "vault-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			payload := map[string]string{}
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			if err != nil || payload["common_name"] != leaf.cert.Subject.CommonName {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			response := map[string]map[string]string{
+				"data": {
+					"certificate":      string(leaf.certPem),
+					"issuing_ca":       string(ca.certPem),
+					"private_key":      string(leaf.keyPem),
+					"private_key_type": keyType,
+				},
+			}
+			_ = json.NewEncoder(w).Encode(response)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	addr, token, mount, role, ttl := srv.URL, "vault-token", "pki", "printers", ""
+	renewBefore := 30 * 24 * time.Hour
+	vCfg := &vaultCfg{
+		addr:        &addr,
+		token:       &token,
+		mount:       &mount,
+		role:        &role,
+		ttl:         &ttl,
+		renewBefore: &renewBefore,
+	}
+
+	return srv, vCfg
+}
+
+func TestVaultGetPemBytes(t *testing.T) {
+	ca := newTestCert(t, "Test Root CA", time.Now().Add(365*24*time.Hour), nil)
+	leaf := newTestCert(t, "printer.example.com", time.Now().Add(90*24*time.Hour), ca)
+
+	tests := []struct {
+		name     string
+		hostname string
+		keyType  string
+		wantErr  string
+	}{
+		{
+			name:     "success",
+			hostname: "printer.example.com",
+			keyType:  "rsa",
+		},
+		{
+			name:     "non-rsa key",
+			hostname: "printer.example.com",
+			keyType:  "ec",
+			wantErr:  "must be configured to issue rsa keys",
+		},
+		{
+			name:     "no hostname",
+			hostname: "",
+			keyType:  "rsa",
+			wantErr:  "requires the printer hostname",
+		},
+		{
+			name:     "vault refuses the common name",
+			hostname: "other.example.com",
+			keyType:  "rsa",
+			wantErr:  "status code 400",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, vCfg := newTestVault(t, ca, leaf, tt.keyType)
+			defer srv.Close()
+
+			keyPem, certPem, err := vCfg.getPemBytes("test", tt.hostname)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error is %v, want one containing '%s'", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			if !bytes.Equal(keyPem, leaf.keyPem) {
+				t.Errorf("key pem isn't the issued key")
+			}
+			// the root issuing ca isn't included in the chain
+			if !bytes.Equal(certPem, leaf.certPem) {
+				t.Errorf("cert pem is %q, want only the issued cert", certPem)
+			}
+		})
+	}
+}
+
+func TestVaultNeedsNewCert(t *testing.T) {
+	ca := newTestCert(t, "Test Root CA", time.Now().Add(365*24*time.Hour), nil)
+	otherCA := newTestCert(t, "Other Root CA", time.Now().Add(365*24*time.Hour), nil)
+
+	tests := []struct {
+		name     string
+		hostname string
+		current  *testCert
+		want     bool
+	}{
+		{
+			name:     "current cert is good",
+			hostname: "printer.example.com",
+			current:  newTestCert(t, "printer.example.com", time.Now().Add(90*24*time.Hour), ca),
+			want:     false,
+		},
+		{
+			name:     "current cert is unknown",
+			hostname: "printer.example.com",
+			current:  nil,
+			want:     true,
+		},
+		{
+			name:     "current cert is due for renewal",
+			hostname: "printer.example.com",
+			current:  newTestCert(t, "printer.example.com", time.Now().Add(7*24*time.Hour), ca),
+			want:     true,
+		},
+		{
+			name:     "current cert is for another hostname",
+			hostname: "printer.example.com",
+			current:  newTestCert(t, "other.example.com", time.Now().Add(90*24*time.Hour), ca),
+			want:     true,
+		},
+		{
+			name:     "current cert wasn't issued by vault",
+			hostname: "printer.example.com",
+			current:  newTestCert(t, "printer.example.com", time.Now().Add(90*24*time.Hour), otherCA),
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, vCfg := newTestVault(t, ca, ca, "rsa")
+			defer srv.Close()
+
+			got, err := vCfg.needsNewCert("test", tt.hostname, certOf(tt.current))
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package app
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// audit output formats
+const (
+	auditFormatTable = "table"
+	auditFormatJSON  = "json"
+)
+
+// audit finding statuses
+const (
+	auditStatusPass    = "pass"
+	auditStatusFail    = "fail"
+	auditStatusUnknown = "unknown"
+)
+
+// audit finding severities and how many points a failed finding of each
+// severity deducts from the printer's score (out of 100)
+const (
+	auditSeverityHigh   = "high"
+	auditSeverityMedium = "medium"
+	auditSeverityLow    = "low"
+)
+
+var auditSeverityPoints = map[string]int{
+	auditSeverityHigh:   25,
+	auditSeverityMedium: 10,
+	auditSeverityLow:    5,
+}
+
+// the minimum tls version that passes the audit
+const auditMinTLSVersion = "1.2"
+
+// regexAuditTLSVersion matches the tls versions that can be compared to
+// auditMinTLSVersion
+var regexAuditTLSVersion = regexp.MustCompile(`^1\.[0-3]$`)
+
+// passwords Brother printers have shipped with as the default (newer models use
+// a per-unit password printed on the printer, see --default-password)
+var auditKnownDefaultPasswords = []string{"initpass", "access"}
+
+// auditService is a service whose state is reported by the audit
+type auditService struct {
+	check    string
+	severity string
+	// regexLabel matches the service's label on the Protocol page
+	regexLabel *regexp.Regexp
+}
+
+// services on the Protocol page reported by the audit (enabled == fail). Plain
+// http is reported from the HTTP Server Settings page instead since the
+// Protocol page's HTTP Server also controls https.
+var auditServices = []auditService{
+	{check: "service_telnet", severity: auditSeverityHigh, regexLabel: regexp.MustCompile(`(?i)^telnet`)},
+	{check: "service_ftp", severity: auditSeverityMedium, regexLabel: regexp.MustCompile(`(?i)^ftp`)},
+	{check: "service_tftp", severity: auditSeverityMedium, regexLabel: regexp.MustCompile(`(?i)^tftp`)},
+	{check: "service_ipp", severity: auditSeverityLow, regexLabel: regexp.MustCompile(`(?i)^ipp`)},
+	{check: "service_raw_port", severity: auditSeverityLow, regexLabel: regexp.MustCompile(`(?i)raw\s*port|9100`)},
+	{check: "service_lpd", severity: auditSeverityLow, regexLabel: regexp.MustCompile(`(?i)^lpd`)},
+}
+
+// auditCfg contains the config options for the audit subcommand
+type auditCfg struct {
+	format          *string
+	defaultPassword *string
+	warningDays     *int
+}
+
+// auditFinding is the result of one audit check
+type auditFinding struct {
+	Check    string `json:"check"`
+	Status   string `json:"status"`
+	Severity string `json:"severity"`
+	Detail   string `json:"detail"`
+}
+
+// auditReport is the audit of one printer
+type auditReport struct {
+	Printer  string         `json:"printer"`
+	Hostname string         `json:"hostname"`
+	Score    int            `json:"score"`
+	Findings []auditFinding `json:"findings"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// add appends a finding to the report
+func (r *auditReport) add(check, severity string, pass bool, detail string) {
+	status := auditStatusFail
+	if pass {
+		status = auditStatusPass
+	}
+
+	r.Findings = append(r.Findings, auditFinding{
+		Check:    check,
+		Status:   status,
+		Severity: severity,
+		Detail:   detail,
+	})
+}
+
+// addUnknown appends a finding that couldn't be checked to the report
+func (r *auditReport) addUnknown(check, severity string, err error) {
+	r.Findings = append(r.Findings, auditFinding{
+		Check:    check,
+		Status:   auditStatusUnknown,
+		Severity: severity,
+		Detail:   err.Error(),
+	})
+}
+
+// score calculates the report's score from its failed findings
+func (r *auditReport) score() {
+	r.Score = 100
+	for _, finding := range r.Findings {
+		if finding.Status == auditStatusFail {
+			r.Score -= auditSeverityPoints[finding.Severity]
+		}
+	}
+
+	r.Score = max(r.Score, 0)
+}
+
+// auditTLSMinVersion adds the finding for the side's (server or client)
+// minimum tls version to the report. The version is unknown if the printer's
+// selected option couldn't be read as "1.x".
+func auditTLSMinVersion(r *auditReport, check, severity, side, version string) {
+	if !regexAuditTLSVersion.MatchString(version) {
+		r.addUnknown(check, severity, fmt.Errorf("%s minimum version couldn't be read (%q)", side, version))
+		return
+	}
+
+	// validated versions are all "1.x" so they compare as strings
+	r.add(check, severity, version >= auditMinTLSVersion, fmt.Sprintf("%s minimum version is TLS %s", side, version))
+}
+
+// auditProtocols adds the Protocol page service findings to the report
+func auditProtocols(r *auditReport, protocols map[string]bool) {
+	// sorted so output is consistent
+	labels := []string{}
+	for label := range protocols {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, service := range auditServices {
+		found := false
+		for _, label := range labels {
+			if !service.regexLabel.MatchString(label) {
+				continue
+			}
+
+			found = true
+			if protocols[label] {
+				r.add(service.check, service.severity, false, fmt.Sprintf("%s is enabled", label))
+			} else {
+				r.add(service.check, service.severity, true, fmt.Sprintf("%s is disabled", label))
+			}
+			break
+		}
+
+		if !found {
+			r.addUnknown(service.check, service.severity, errors.New("not found on the protocol page"))
+		}
+	}
+}
+
+// auditGetCurrentLeafCert returns the printer's current cert (no login needed)
+func auditGetCurrentLeafCert(printerCfg printer.Config) (*x509.Certificate, error) {
+	print, err := printer.NewPrinterNoLogin(printerCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return print.GetCurrentLeafCert()
+}
+
+// auditPrinter audits one printer. defaultPasswords are the passwords that
+// are considered default.
+func auditPrinter(name string, printerCfg printer.Config, defaultPasswords []string, warningDays int) auditReport {
+	r := auditReport{
+		Printer:  name,
+		Hostname: printerCfg.Hostname,
+		Findings: []auditFinding{},
+	}
+
+	// default password (checked locally, no extra login attempts are made)
+	isDefault := false
+	for _, defaultPassword := range defaultPasswords {
+		if defaultPassword != "" && printerCfg.Password == defaultPassword {
+			isDefault = true
+		}
+	}
+	if isDefault {
+		r.add("default_password", auditSeverityHigh, false, "the default administrator password is in use")
+	} else {
+		r.add("default_password", auditSeverityHigh, true, "the administrator password is not a known default")
+	}
+
+	// cert expiry (no login needed)
+	certCheck := "certificate_expiry"
+	cert, err := auditGetCurrentLeafCert(printerCfg)
+	if err != nil {
+		r.addUnknown(certCheck, auditSeverityHigh, err)
+	} else {
+		daysRemaining := int(math.Floor(time.Until(cert.NotAfter).Hours() / 24))
+		switch {
+		case daysRemaining < 0:
+			r.add(certCheck, auditSeverityHigh, false, fmt.Sprintf("certificate expired %d days ago", -daysRemaining))
+		case daysRemaining < warningDays:
+			r.add(certCheck, auditSeverityMedium, false, fmt.Sprintf("certificate expires in %d days", daysRemaining))
+		default:
+			r.add(certCheck, auditSeverityHigh, true, fmt.Sprintf("certificate expires in %d days", daysRemaining))
+		}
+	}
+
+	// everything else requires login
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		r.Error = err.Error()
+		r.score()
+		return r
+	}
+	defer print.Close()
+
+	// services
+	protocols, err := print.GetProtocolSettings()
+	if err != nil {
+		for _, service := range auditServices {
+			r.addUnknown(service.check, service.severity, err)
+		}
+	} else {
+		auditProtocols(&r, protocols)
+	}
+
+	// snmp v1/v2c (community strings are sent in plain text)
+	snmpV1v2c, err := print.GetSNMPv1v2cEnabled()
+	if err != nil {
+		r.addUnknown("service_snmp_v1_v2c", auditSeverityMedium, err)
+	} else {
+		if snmpV1v2c {
+			r.add("service_snmp_v1_v2c", auditSeverityMedium, false, "snmp v1/v2c access is enabled")
+		} else {
+			r.add("service_snmp_v1_v2c", auditSeverityMedium, true, "snmp v1/v2c access is disabled")
+		}
+	}
+
+	// tls versions
+	tlsSettings, err := print.GetTLSSettings()
+	if err != nil {
+		r.addUnknown("tls_server_min_version", auditSeverityHigh, err)
+		r.addUnknown("tls_client_min_version", auditSeverityMedium, err)
+	} else {
+		auditTLSMinVersion(&r, "tls_server_min_version", auditSeverityHigh, "server", tlsSettings.ServerMinVersion)
+		auditTLSMinVersion(&r, "tls_client_min_version", auditSeverityMedium, "client", tlsSettings.ClientMinVersion)
+	}
+
+	// plain http and https enforced
+	httpSettings, err := print.GetHttpSettings()
+	if err != nil {
+		r.addUnknown("service_http", auditSeverityLow, err)
+		r.addUnknown("https_enforced", auditSeverityMedium, err)
+	} else {
+		if httpSettings.PlainHttp {
+			r.add("service_http", auditSeverityLow, false, "plain http (port 80) is enabled")
+		} else {
+			r.add("service_http", auditSeverityLow, true, "plain http (port 80) is disabled")
+		}
+
+		switch {
+		case !httpSettings.HttpsWebUI:
+			r.add("https_enforced", auditSeverityMedium, false, "https is disabled for the web ui")
+		case httpSettings.PlainHttp && !httpSettings.RedirectHttp:
+			r.add("https_enforced", auditSeverityMedium, false, "plain http is enabled")
+		case httpSettings.PlainHttp:
+			r.add("https_enforced", auditSeverityMedium, true, "plain http is redirected to https")
+		default:
+			r.add("https_enforced", auditSeverityMedium, true, "plain http is disabled")
+		}
+	}
+
+	r.score()
+	return r
+}
+
+// printAuditTable writes the reports as a human readable table to stdout
+func printAuditTable(reports []auditReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "PRINTER\tSCORE\tCHECK\tSTATUS\tSEVERITY\tDETAIL")
+	for _, r := range reports {
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", r.Printer, r.Score, "login", strings.ToUpper(auditStatusUnknown), "", r.Error)
+		}
+
+		for _, f := range r.Findings {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", r.Printer, r.Score, f.Check, strings.ToUpper(f.Status), f.Severity, f.Detail)
+		}
+	}
+
+	return w.Flush()
+}
+
+// cmdAudit audits the security settings of the printer (or all printers in
+// the inventory) and outputs the findings. It does not change anything.
+func (app *app) cmdAudit(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("audit: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	format := *app.config.audit.format
+	if format != auditFormatTable && format != auditFormatJSON {
+		return fmt.Errorf("audit: format must be %s or %s", auditFormatTable, auditFormatJSON)
+	}
+
+	names, printerCfgs, err := app.loginTargets("audit")
+	if err != nil {
+		return err
+	}
+
+	defaultPasswords := append([]string{*app.config.audit.defaultPassword}, auditKnownDefaultPasswords...)
+
+	reports := []auditReport{}
+	var errs []error
+	for i := range printerCfgs {
+		app.stdLogger.Printf("audit: auditing %s...", names[i])
+		r := auditPrinter(names[i], printerCfgs[i], defaultPasswords, *app.config.audit.warningDays)
+		reports = append(reports, r)
+
+		if r.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", names[i], r.Error))
+		}
+	}
+
+	// output
+	switch format {
+	case auditFormatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Printers []auditReport `json:"printers"`
+		}{
+			Printers: reports,
+		})
+	default:
+		err = printAuditTable(reports)
+	}
+	if err != nil {
+		return fmt.Errorf("audit: failed to write output (%w)", err)
+	}
+
+	return errors.Join(errs...)
+}
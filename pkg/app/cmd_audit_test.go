@@ -0,0 +1,35 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestAuditTLSMinVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		wantStatus string
+	}{
+		{name: "minimum version", version: "1.2", wantStatus: auditStatusPass},
+		{name: "newer version", version: "1.3", wantStatus: auditStatusPass},
+		{name: "older version", version: "1.0", wantStatus: auditStatusFail},
+		{name: "older version 1.1", version: "1.1", wantStatus: auditStatusFail},
+		{name: "empty version", version: "", wantStatus: auditStatusUnknown},
+		{name: "unparsed version", version: "SSL3", wantStatus: auditStatusUnknown},
+		{name: "unknown major version", version: "2.0", wantStatus: auditStatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := auditReport{}
+			auditTLSMinVersion(&r, "tls_server_min_version", auditSeverityHigh, "server", tt.version)
+
+			if len(r.Findings) != 1 {
+				t.Fatalf("got %d findings, want 1", len(r.Findings))
+			}
+			if r.Findings[0].Status != tt.wantStatus {
+				t.Errorf("got status %s, want %s (detail: %s)", r.Findings[0].Status, tt.wantStatus, r.Findings[0].Detail)
+			}
+		})
+	}
+}
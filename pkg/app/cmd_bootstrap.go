@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// bootstrapCfg contains the config options for the bootstrap subcommand
+type bootstrapCfg struct {
+	caCertFilePath *string
+	tlsMinVersion  *string
+	disableHttp    *bool
+}
+
+// cmdBootstrap performs the initial ssl setup of a factory-fresh printer over
+// http: it uploads the root CA, sets the minimum TLS version, installs and
+// activates the key/cert, and then verifies https (and optionally disables
+// http)
+func (app *app) cmdBootstrap(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("bootstrap: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	printerCfg, err := app.printerConfig("bootstrap")
+	if err != nil {
+		return err
+	}
+
+	// load key and cert
+	keyPem, certPem, err := app.config.keyCertPemCfg.GetPemBytes("bootstrap", printerCfg.Hostname)
+	if err != nil {
+		return err
+	}
+
+	newCert, err := parseLeafCert(certPem)
+	if err != nil {
+		return err
+	}
+
+	activateOpts, err := app.config.activate.options()
+	if err != nil {
+		return fmt.Errorf("bootstrap: %w", err)
+	}
+	if activateOpts.HttpsWebUI != nil && !*activateOpts.HttpsWebUI {
+		return errors.New("bootstrap: https for the web ui can't be turned off (it is used to verify the new cert)")
+	}
+	// https for the web ui is always turned on (even with keep)
+	httpsWebUIOn := true
+	activateOpts.HttpsWebUI = &httpsWebUIOn
+	// other secure protocols are activated too, unless turned off
+	if *app.config.activate.activateOtherProtocols == "" {
+		activateOpts.ActivateOtherProtocols = true
+	}
+
+	// tls policy (--tls-min-version fills in versions not otherwise specified)
+	tlsPolicy, err := app.config.tlsPolicy.settings()
+	if err != nil {
+		return fmt.Errorf("bootstrap: %w", err)
+	}
+	if tlsPolicy.ServerMinVersion == "" {
+		tlsPolicy.ServerMinVersion = *app.config.bootstrap.tlsMinVersion
+	}
+	if tlsPolicy.ClientMinVersion == "" {
+		tlsPolicy.ClientMinVersion = *app.config.bootstrap.tlsMinVersion
+	}
+
+	// load root CA
+	var caCertPem []byte
+	var roots *x509.CertPool
+	if *app.config.bootstrap.caCertFilePath != "" {
+		caCertPem, err = os.ReadFile(*app.config.bootstrap.caCertFilePath)
+		if err != nil {
+			return fmt.Errorf("bootstrap: failed to read ca cert file (%w)", err)
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caCertPem) {
+			return errors.New("bootstrap: failed to parse ca cert file")
+		}
+	} else {
+		app.stdLogger.Println("WARNING: no ca cert file specified, the printer must already trust the root of the new certificate")
+	}
+
+	// a factory-fresh printer doesn't have a usable https cert yet
+	app.stdLogger.Println("bootstrap: using http for initial setup")
+	printerCfg.UseHttp = true
+
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return err
+	}
+	defer print.Close()
+	app.stdLogger.Println("bootstrap: connected to printer")
+
+	// 1. root CA
+	if caCertPem != nil {
+		app.stdLogger.Println("bootstrap: uploading root ca...")
+		caId, err := print.UploadCACert(caCertPem)
+		if err != nil {
+			return err
+		}
+		app.stdLogger.Printf("bootstrap: root ca installed (id: %s)", caId)
+	}
+
+	// 2. TLS settings
+	if tlsPolicy.ServerMinVersion != "" || tlsPolicy.ClientMinVersion != "" || tlsPolicy.CipherStrength != "" {
+		err = applyTLSPolicy(app.stdLogger, "bootstrap", print, tlsPolicy)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. key/cert
+	app.stdLogger.Println("bootstrap: uploading new cert...")
+	newCertId, err := uploadCert(app.stdLogger, "bootstrap", print, *app.config.stateFilePath, printerCfg.Hostname, keyPem, certPem)
+	if err != nil {
+		return err
+	}
+	app.stdLogger.Printf("bootstrap: new printer cert installed (but not yet activated) (id: %s)", newCertId)
+
+	// 4. activate and reboot
+	app.stdLogger.Printf("bootstrap: activating cert (id: %s) and rebooting... please wait %s...", newCertId, print.RebootWait())
+	err = print.SetActiveCert(newCertId, activateOpts)
+	if err != nil {
+		// don't leave the uploaded cert behind
+		return print.RollBackUpload(newCertId, err)
+	}
+	time.Sleep(print.RebootWait())
+
+	// 5. switch to https and verify
+	printerCfg.UseHttp = false
+	printerCfg.RootCAs = roots
+
+	err = verifyServedCert(printerCfg, newCert, roots)
+	if err != nil {
+		return fmt.Errorf("bootstrap: https verification failed (%w)", err)
+	}
+	app.stdLogger.Println("bootstrap: printer is serving the new cert over https")
+
+	if app.config.stateFilePath != nil && *app.config.stateFilePath != "" {
+		err = recordInstall(*app.config.stateFilePath, printerCfg.Hostname, time.Now())
+		if err != nil {
+			app.stdLogger.Printf("bootstrap: failed to record install in state file (%s)", err)
+		}
+	}
+
+	// 6. disable http
+	if *app.config.bootstrap.disableHttp {
+		err = enforceHttpsOnly(app.stdLogger, "bootstrap", printerCfg, newCert, httpsOnlyDisable)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
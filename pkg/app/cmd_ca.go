@@ -0,0 +1,191 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/ca"
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// caCfg contains the config options for the ca subcommands
+type caCfg struct {
+	keyFilePath     *string
+	certFilePath    *string
+	commonName      *string
+	initDays        *int
+	issueDays       *int
+	sans            *[]string
+	issueAndInstall *bool
+	noCAUpload      *bool
+}
+
+// writeNewFile writes data to the named file, but only if the file does not
+// already exist
+func writeNewFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// cmdCAInit creates a new local root CA and saves its key and cert to disk
+func (app *app) cmdCAInit(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("ca init: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	if *app.config.ca.keyFilePath == "" || *app.config.ca.certFilePath == "" {
+		return errors.New("ca init: ca key file and ca cert file must be specified")
+	}
+
+	keyPem, certPem, err := ca.NewRootCA(*app.config.ca.commonName, time.Duration(*app.config.ca.initDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	// never overwrite an existing CA
+	err = writeNewFile(*app.config.ca.keyFilePath, keyPem, 0600)
+	if err != nil {
+		return fmt.Errorf("ca init: failed to write ca key file (%w)", err)
+	}
+
+	err = writeNewFile(*app.config.ca.certFilePath, certPem, 0644)
+	if err != nil {
+		return fmt.Errorf("ca init: failed to write ca cert file (%w)", err)
+	}
+
+	app.stdLogger.Printf("ca init: root ca created (key: %s, cert: %s)", *app.config.ca.keyFilePath, *app.config.ca.certFilePath)
+
+	return nil
+}
+
+// cmdCAIssue issues a new server certificate for the printer from the local
+// root CA. the key and cert are saved to disk and/or installed on the printer.
+func (app *app) cmdCAIssue(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("ca issue: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	if app.config.hostname == nil || *app.config.hostname == "" {
+		return errors.New("ca issue: hostname must be specified")
+	}
+
+	// where to save the new key and cert (optional if installing)
+	keyOutPath := *app.config.keyCertPemCfg.keyPemFilePath
+	certOutPath := *app.config.keyCertPemCfg.certPemFilePath
+	if (keyOutPath == "") != (certOutPath == "") {
+		return errors.New("ca issue: key file and cert file must be specified together")
+	}
+	if keyOutPath == "" && !*app.config.ca.issueAndInstall {
+		return errors.New("ca issue: key file and cert file must be specified (or use --issue-and-install)")
+	}
+
+	// validate printer config before doing any work
+	var printerCfg printer.Config
+	if *app.config.ca.issueAndInstall {
+		var err error
+		printerCfg, err = app.printerConfig("ca issue")
+		if err != nil {
+			return err
+		}
+	}
+
+	// load CA
+	caKeyPem, err := os.ReadFile(*app.config.ca.keyFilePath)
+	if err != nil {
+		return fmt.Errorf("ca issue: failed to read ca key file (%w)", err)
+	}
+	caCertPem, err := os.ReadFile(*app.config.ca.certFilePath)
+	if err != nil {
+		return fmt.Errorf("ca issue: failed to read ca cert file (%w)", err)
+	}
+
+	// issue
+	hostnames := append([]string{*app.config.hostname}, *app.config.ca.sans...)
+	keyPem, certPem, err := ca.IssueServerCert(caKeyPem, caCertPem, hostnames, time.Duration(*app.config.ca.issueDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	app.stdLogger.Printf("ca issue: certificate issued for %s", *app.config.hostname)
+
+	// save
+	if keyOutPath != "" {
+		err = os.WriteFile(keyOutPath, keyPem, 0600)
+		if err != nil {
+			return fmt.Errorf("ca issue: failed to write key file (%w)", err)
+		}
+
+		err = os.WriteFile(certOutPath, certPem, 0644)
+		if err != nil {
+			return fmt.Errorf("ca issue: failed to write cert file (%w)", err)
+		}
+
+		app.stdLogger.Printf("ca issue: key and cert saved (key: %s, cert: %s)", keyOutPath, certOutPath)
+	}
+
+	// done if not also installing
+	if !*app.config.ca.issueAndInstall {
+		return nil
+	}
+
+	// push root to the printer's trusted CAs
+	if !*app.config.ca.noCAUpload {
+		err = app.uploadRootCA(printerCfg, caCertPem)
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.installCertAndReset(app.stdLogger, printerCfg, keyPem, certPem)
+}
+
+// uploadRootCA uploads the root CA to the printer's trusted CAs, unless the
+// printer already has it (the printer's storage is limited, so it shouldn't
+// be uploaded again on every issue)
+func (app *app) uploadRootCA(printerCfg printer.Config, caCertPem []byte) error {
+	caCert, err := parseLeafCert(caCertPem)
+	if err != nil {
+		return fmt.Errorf("ca issue: %w", err)
+	}
+
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return err
+	}
+	defer print.Close()
+	app.stdLogger.Println("ca issue: connected to printer")
+
+	currCAs, err := print.ListCACerts()
+	if err != nil {
+		return err
+	}
+	for _, currCA := range currCAs {
+		if bytes.Equal(currCA.Serial, caCert.SerialNumber.Bytes()) {
+			app.stdLogger.Printf("ca issue: root ca is already installed (id: %s)", currCA.ID)
+			return nil
+		}
+	}
+
+	app.stdLogger.Println("ca issue: uploading root ca...")
+	caId, err := print.UploadCACert(caCertPem)
+	if err != nil {
+		return err
+	}
+	app.stdLogger.Printf("ca issue: root ca installed (id: %s)", caId)
+
+	return nil
+}
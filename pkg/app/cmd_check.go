@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// monitoring plugin (Nagios/Icinga) states, which are also the exit codes
+const (
+	checkStateOK       = 0
+	checkStateWarning  = 1
+	checkStateCritical = 2
+	checkStateUnknown  = 3
+)
+
+var checkStateNames = map[int]string{
+	checkStateOK:       "OK",
+	checkStateWarning:  "WARNING",
+	checkStateCritical: "CRITICAL",
+	checkStateUnknown:  "UNKNOWN",
+}
+
+// checkStateSeverity orders the states for choosing the overall state of a
+// fleet check (an unknown printer shouldn't mask an expiring one)
+var checkStateSeverity = map[int]int{
+	checkStateOK:       0,
+	checkStateUnknown:  1,
+	checkStateWarning:  2,
+	checkStateCritical: 3,
+}
+
+// checkCfg contains the config options for the check subcommand
+type checkCfg struct {
+	warningDays  *int
+	criticalDays *int
+}
+
+// checkResult is the result of checking one printer
+type checkResult struct {
+	hostname      string
+	state         int
+	daysRemaining int
+	notAfter      time.Time
+	err           error
+}
+
+// message returns the human readable status of the result
+func (r checkResult) message() string {
+	if r.err != nil {
+		return fmt.Sprintf("%s: %s", r.hostname, r.err)
+	}
+
+	if r.daysRemaining < 0 {
+		return fmt.Sprintf("%s: certificate expired %d days ago (%s)", r.hostname, -r.daysRemaining, r.notAfter.UTC().Format(time.RFC3339))
+	}
+
+	return fmt.Sprintf("%s: certificate expires in %d days (%s)", r.hostname, r.daysRemaining, r.notAfter.UTC().Format(time.RFC3339))
+}
+
+// checkHostnames returns the list of printer hostnames to check, from args,
+// --hostname, and --inventory
+func (app *app) checkHostnames(args []string) ([]string, error) {
+	hostnames := []string{}
+	if app.config.hostname != nil && *app.config.hostname != "" {
+		hostnames = append(hostnames, *app.config.hostname)
+	}
+
+	hostnames = append(hostnames, args...)
+
+	if app.config.inventoryPath != nil && *app.config.inventoryPath != "" {
+		inv, err := loadInventory(*app.config.inventoryPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range inv.Printers {
+			hostnames = append(hostnames, p.Hostname)
+		}
+	}
+
+	return hostnames, nil
+}
+
+// checkPrinter checks the expiration of the specified printer's current cert
+// against the warning and critical thresholds
+func checkPrinter(hostname string, warningDays, criticalDays int) checkResult {
+	result := checkResult{
+		hostname: hostname,
+		state:    checkStateUnknown,
+	}
+
+	// handshake doesn't need login
+	print, err := printer.NewPrinterNoLogin(printer.Config{
+		Hostname:  hostname,
+		UserAgent: userAgent(),
+	})
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	cert, err := print.GetCurrentLeafCert()
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.notAfter = cert.NotAfter
+	result.daysRemaining = int(math.Floor(time.Until(cert.NotAfter).Hours() / 24))
+
+	switch {
+	case result.daysRemaining < criticalDays:
+		result.state = checkStateCritical
+	case result.daysRemaining < warningDays:
+		result.state = checkStateWarning
+	default:
+		result.state = checkStateOK
+	}
+
+	return result
+}
+
+// cmdCheck checks the expiration of one or more printers' current certs and
+// outputs the result in monitoring plugin (Nagios/Icinga) format. The exit code
+// is the plugin state.
+func (app *app) cmdCheck(_ context.Context, args []string) error {
+	// unknown is used for any problem with the check itself
+	unknown := func(err error) error {
+		fmt.Printf("BROTHER-CERT %s - %s\n", checkStateNames[checkStateUnknown], err)
+		return &exitCodeError{code: checkStateUnknown}
+	}
+
+	warningDays := *app.config.check.warningDays
+	criticalDays := *app.config.check.criticalDays
+	if criticalDays < 0 || warningDays < criticalDays {
+		return unknown(fmt.Errorf("invalid thresholds (warning %d, critical %d), warning must be >= critical >= 0", warningDays, criticalDays))
+	}
+
+	hostnames, err := app.checkHostnames(args)
+	if err != nil {
+		return unknown(err)
+	}
+	if len(hostnames) <= 0 {
+		return unknown(fmt.Errorf("no printer hostname(s) specified"))
+	}
+
+	// check each printer
+	results := []checkResult{}
+	stateCounts := make(map[int]int)
+	worstState := checkStateOK
+	for _, hostname := range hostnames {
+		result := checkPrinter(hostname, warningDays, criticalDays)
+		results = append(results, result)
+		stateCounts[result.state]++
+
+		if checkStateSeverity[result.state] > checkStateSeverity[worstState] {
+			worstState = result.state
+		}
+	}
+
+	// perfdata
+	perfData := []string{}
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		perfData = append(perfData, fmt.Sprintf("'%s_days_remaining'=%d;%d:;%d:", result.hostname, result.daysRemaining, warningDays, criticalDays))
+	}
+
+	// output
+	summary := ""
+	if len(results) == 1 {
+		summary = results[0].message()
+	} else {
+		summary = fmt.Sprintf("%d printers: %d critical, %d warning, %d unknown, %d ok", len(results),
+			stateCounts[checkStateCritical], stateCounts[checkStateWarning], stateCounts[checkStateUnknown], stateCounts[checkStateOK])
+	}
+
+	if len(perfData) > 0 {
+		summary += " | " + strings.Join(perfData, " ")
+	}
+	fmt.Printf("BROTHER-CERT %s - %s\n", checkStateNames[worstState], summary)
+
+	// long output (one line per printer) for fleet checks
+	if len(results) > 1 {
+		for _, result := range results {
+			fmt.Printf("[%s] %s\n", checkStateNames[result.state], result.message())
+		}
+	}
+
+	if worstState == checkStateOK {
+		return nil
+	}
+
+	return &exitCodeError{code: worstState}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// https only modes
+const (
+	// turn off plain http
+	httpsOnlyDisable = "disable"
+	// redirect plain http to https
+	httpsOnlyRedirect = "redirect"
+)
+
+// httpsOnlyCfg contains the config options for the https-only subcommand
+type httpsOnlyCfg struct {
+	redirect *bool
+}
+
+// verifyServedCert performs a tls handshake with the printer and confirms it
+// is serving expectedCert. If roots is not nil, the served chain must also
+// verify for the printer's hostname.
+func verifyServedCert(printerCfg printer.Config, expectedCert *x509.Certificate, roots *x509.CertPool) error {
+	print, err := printer.NewPrinterNoLogin(printerCfg)
+	if err != nil {
+		return err
+	}
+
+	servedCert, err := print.GetCurrentLeafCert()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(servedCert.SerialNumber.Bytes(), expectedCert.SerialNumber.Bytes()) {
+		return errors.New("printer is not serving the new certificate")
+	}
+
+	if roots != nil {
+		_, err = servedCert.Verify(x509.VerifyOptions{
+			DNSName: printerCfg.Hostname,
+			Roots:   roots,
+		})
+		if err != nil {
+			return fmt.Errorf("served certificate did not verify (%w)", err)
+		}
+	}
+
+	return nil
+}
+
+// enforceHttpsOnly confirms the printer's https web ui works (and, if
+// expectedCert is not nil, is serving expectedCert) and then either disables
+// plain http or redirects it to https (depending on mode). If https doesn't
+// work, http is left as-is so the printer can't be locked out. If http is
+// already disabled (or redirected), nothing is changed. progress is logged to
+// logger.
+func enforceHttpsOnly(logger *log.Logger, subcommand string, printerCfg printer.Config, expectedCert *x509.Certificate, mode string) error {
+	printerCfg.UseHttp = false
+
+	// confirm https works first
+	if expectedCert != nil {
+		err := verifyServedCert(printerCfg, expectedCert, printerCfg.RootCAs)
+		if err != nil {
+			return fmt.Errorf("%s: https verification failed, not changing http (%w)", subcommand, err)
+		}
+	}
+
+	// login also verifies the served cert is trusted and valid for the hostname
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return fmt.Errorf("%s: failed to connect to printer over https, not changing http (%w)", subcommand, err)
+	}
+	// close whichever session is current (the printer is reconnected after
+	// the reboot)
+	defer func() { _ = print.Close() }()
+	logger.Printf("%s: connected to printer over https", subcommand)
+
+	// nothing to do (and no reboot) if http is already as wanted
+	settings, err := print.GetHttpSettings()
+	if err != nil {
+		return err
+	}
+	if (mode == httpsOnlyDisable && !settings.PlainHttp) || (mode == httpsOnlyRedirect && settings.RedirectHttp) {
+		logger.Printf("%s: printer web ui is already https only", subcommand)
+		return nil
+	}
+
+	switch mode {
+	case httpsOnlyDisable:
+		logger.Printf("%s: disabling http and rebooting... please wait %s...", subcommand, print.RebootWait())
+		err = print.DisableHttp()
+	case httpsOnlyRedirect:
+		logger.Printf("%s: redirecting http to https and rebooting... please wait %s...", subcommand, print.RebootWait())
+		err = print.RedirectHttp()
+	default:
+		err = fmt.Errorf("%s: invalid https only mode '%s' (must be %s or %s)", subcommand, mode, httpsOnlyDisable, httpsOnlyRedirect)
+	}
+	if err != nil {
+		return err
+	}
+	time.Sleep(print.RebootWait())
+
+	print, err = printer.NewPrinter(printerCfg)
+	if err != nil {
+		return fmt.Errorf("%s: failed to reconnect to printer over https after changing http (%w)", subcommand, err)
+	}
+	logger.Printf("%s: printer web ui is https only", subcommand)
+
+	return nil
+}
+
+// cmdHttpsOnly disables plain http (or redirects it to https) on the printer
+// (or all printers in the inventory), after confirming https works
+func (app *app) cmdHttpsOnly(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("https-only: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	mode := httpsOnlyDisable
+	if *app.config.httpsOnly.redirect {
+		mode = httpsOnlyRedirect
+	}
+
+	names, printerCfgs, err := app.loginTargets("https-only")
+	if err != nil {
+		return err
+	}
+
+	// one failure shouldn't stop the rest
+	var errs []error
+	for i := range printerCfgs {
+		if printerCfgs[i].UseHttp {
+			app.stdLogger.Printf("WARNING: https-only: %s is configured to use http, https will be used instead", names[i])
+		}
+
+		err = enforceHttpsOnly(app.stdLogger, "https-only", printerCfgs[i], nil, mode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -7,27 +7,52 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"log"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/gregtwallace/brother-cert/pkg/printer"
 )
 
-// cmdInstallCertAndReset executes a series of commands against a brother printer
-// to install the specified ssl key and cert. it then deletes the old cert and
-// resets the printer so it will load the newly installed key/cert
-func (app *app) cmdInstallCertAndReset(_ context.Context, args []string) error {
-	// extra args == error
-	if len(args) != 0 {
-		return fmt.Errorf("main: failed, %w (%d)", ErrExtraArgs, len(args))
+// userAgent returns the User-Agent the app uses when connecting to printers
+func userAgent() string {
+	return fmt.Sprintf("brother-cert/%s (%s; %s)", appVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+// identityCfg is the expected identity of the printer specified in the app's
+// config
+type identityCfg struct {
+	serialNumber *string
+	macAddress   *string
+	nodeName     *string
+}
+
+// identity returns the expected printer.Identity (empty values aren't checked)
+func (cfg identityCfg) identity() printer.Identity {
+	identity := printer.Identity{}
+	if cfg.serialNumber != nil {
+		identity.SerialNumber = *cfg.serialNumber
+	}
+	if cfg.macAddress != nil {
+		identity.MACAddress = *cfg.macAddress
+	}
+	if cfg.nodeName != nil {
+		identity.NodeName = *cfg.nodeName
 	}
 
+	return identity
+}
+
+// printerConfig returns the printer.Config for the printer specified in the
+// app's config (hostname, password, etc.)
+func (app *app) printerConfig(subcommand string) (printer.Config, error) {
 	// must have hostname and password
 	if app.config.hostname == nil || *app.config.hostname == "" {
-		return errors.New("main: hostname must be specified")
+		return printer.Config{}, fmt.Errorf("%s: hostname must be specified", subcommand)
 	}
 	if app.config.password == nil || *app.config.password == "" {
-		return errors.New("main: hostname must be specified")
+		return printer.Config{}, fmt.Errorf("%s: password must be specified", subcommand)
 	}
 
 	// use http?
@@ -37,101 +62,378 @@ func (app *app) cmdInstallCertAndReset(_ context.Context, args []string) error {
 		useHttp = true
 	}
 
+	// profile override?
+	var profile *printer.Profile
+	if app.config.profilePath != nil && *app.config.profilePath != "" {
+		var err error
+		profile, err = loadProfileFile(*app.config.profilePath)
+		if err != nil {
+			return printer.Config{}, fmt.Errorf("%s: %w", subcommand, err)
+		}
+	}
+
+	return printer.Config{
+		Hostname:         *app.config.hostname,
+		Password:         *app.config.password,
+		UseHttp:          useHttp,
+		UserAgent:        userAgent(),
+		Profile:          profile,
+		ExpectedIdentity: app.config.identity.identity(),
+		SessionCachePath: app.sessionCachePath(),
+	}, nil
+}
+
+// sessionCachePath returns the session cache file path, or an empty string
+// if sessions aren't cached
+func (app *app) sessionCachePath() string {
+	if app.config.sessionCache == nil {
+		return ""
+	}
+
+	return *app.config.sessionCache
+}
+
+// parseLeafCert returns the first (leaf) certificate in certPem
+func parseLeafCert(certPem []byte) (*x509.Certificate, error) {
+	// decode leaf cert
+	certPemBlock, _ := pem.Decode(certPem)
+	if certPemBlock == nil {
+		return nil, errors.New("main: failed to decode new leaf cert pem block")
+	}
+
+	// parse 1st cert
+	cert, err := x509.ParseCertificate(certPemBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new leaf certificate (%s)", err)
+	}
+
+	return cert, nil
+}
+
+// currentLeafCert returns the cert the printer is serving over https, or nil
+// if it can't be fetched (e.g. https is off)
+func currentLeafCert(printerCfg printer.Config) *x509.Certificate {
+	print, err := printer.NewPrinterNoLogin(printerCfg)
+	if err != nil {
+		return nil
+	}
+
+	cert, err := print.GetCurrentLeafCert()
+	if err != nil {
+		return nil
+	}
+
+	return cert
+}
+
+// cmdInstallCertAndReset executes a series of commands against a brother printer
+// to install the specified ssl key and cert. it then deletes the old cert and
+// resets the printer so it will load the newly installed key/cert
+func (app *app) cmdInstallCertAndReset(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("main: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	// acme client deploy hook
+	if app.config.hookMode != nil && *app.config.hookMode != "" {
+		return app.cmdHookInstall()
+	}
+
+	printerCfg, err := app.printerConfig("main")
+	if err != nil {
+		return err
+	}
+
+	// a source that issues certs only issues one when the printer's current
+	// cert is due for renewal (a new cert would never match the current one)
+	if app.config.keyCertPemCfg.issuesCerts() {
+		renew, err := app.config.keyCertPemCfg.needsNewCert("main", printerCfg.Hostname, currentLeafCert(printerCfg))
+		if err != nil {
+			return err
+		}
+		if !renew {
+			app.stdLogger.Println("main: current printer certificate isn't due for renewal, not issuing a new one")
+			return app.installCertAndReset(app.stdLogger, printerCfg, nil, nil)
+		}
+	}
+
 	// load key and cert
-	keyPem, certPem, err := app.config.keyCertPemCfg.GetPemBytes("main")
+	keyPem, certPem, err := app.config.keyCertPemCfg.GetPemBytes("main", printerCfg.Hostname)
 	if err != nil {
 		return err
 	}
 
-	// make printer (which includes login)
-	printerCfg := printer.Config{
-		Hostname:  *app.config.hostname,
-		Password:  *app.config.password,
-		UseHttp:   useHttp,
-		UserAgent: fmt.Sprintf("brother-cert/%s (%s; %s)", appVersion, runtime.GOOS, runtime.GOARCH),
+	return app.installCertAndReset(app.stdLogger, printerCfg, keyPem, certPem)
+}
+
+// activateCfg contains the options for which protocols the new cert is
+// activated for
+type activateCfg struct {
+	httpsWebUI             *string
+	httpsIPP               *string
+	activateOtherProtocols *string
+}
+
+// parseOnOffKeep returns a pointer to true (on) or false (off), or nil (keep)
+func parseOnOffKeep(flagName, value string) (*bool, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		b := true
+		return &b, nil
+	case "off":
+		b := false
+		return &b, nil
+	case "keep":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("--%s must be on, off, or keep", flagName)
 	}
+}
 
-	print, err := printer.NewPrinter(printerCfg)
+// options returns the printer.ActivateCertOptions specified by cfg
+func (cfg activateCfg) options() (opts printer.ActivateCertOptions, err error) {
+	opts.HttpsWebUI, err = parseOnOffKeep("https-webui", *cfg.httpsWebUI)
+	if err != nil {
+		return printer.ActivateCertOptions{}, err
+	}
+
+	opts.HttpsIPP, err = parseOnOffKeep("https-ipp", *cfg.httpsIPP)
+	if err != nil {
+		return printer.ActivateCertOptions{}, err
+	}
+
+	// not specified is off (the subcommand may use another default)
+	switch strings.ToLower(*cfg.activateOtherProtocols) {
+	case "on":
+		opts.ActivateOtherProtocols = true
+	case "off", "":
+		opts.ActivateOtherProtocols = false
+	default:
+		return printer.ActivateCertOptions{}, errors.New("--activate-other-protocols must be on or off")
+	}
+
+	return opts, nil
+}
+
+// installOptions are the optional parts of the install flow
+type installOptions struct {
+	// tlsPolicy, if not nil, is applied to the printer's TLS settings
+	tlsPolicy *printer.TLSSettings
+	// activate controls which protocols the new cert is activated for
+	activate printer.ActivateCertOptions
+	// httpsOnlyMode, if not empty, turns off (or redirects) plain http after
+	// the install
+	httpsOnlyMode string
+	// stateFilePath, if not empty, is where the certs this tool uploads are
+	// recorded, so old ones can be pruned when the printer's storage is full
+	stateFilePath string
+}
+
+// installOptions returns the installOptions specified in the app's config
+func (app *app) installOptions() (installOptions, error) {
+	activateOpts, err := app.config.activate.options()
+	if err != nil {
+		return installOptions{}, fmt.Errorf("main: %w", err)
+	}
+
+	opts := installOptions{
+		activate:      activateOpts,
+		httpsOnlyMode: *app.config.httpsOnlyMode,
+	}
+	if app.config.stateFilePath != nil {
+		opts.stateFilePath = *app.config.stateFilePath
+	}
+
+	switch opts.httpsOnlyMode {
+	case "", httpsOnlyDisable, httpsOnlyRedirect:
+		// valid
+	default:
+		return installOptions{}, fmt.Errorf("main: --https-only must be %s or %s", httpsOnlyDisable, httpsOnlyRedirect)
+	}
+
+	if opts.httpsOnlyMode != "" && opts.activate.HttpsWebUI != nil && !*opts.activate.HttpsWebUI {
+		return installOptions{}, errors.New("main: --https-only can't be used with --https-webui off")
+	}
+
+	if app.config.tlsPolicy.configured() {
+		policy, err := app.config.tlsPolicy.settings()
+		if err != nil {
+			return installOptions{}, fmt.Errorf("main: %w", err)
+		}
+		opts.tlsPolicy = &policy
+	}
+
+	return opts, nil
+}
+
+// installCertAndReset runs installCertAndReset with the app's installOptions
+// and, if a state file is configured, records the time of the install
+func (app *app) installCertAndReset(logger *log.Logger, printerCfg printer.Config, keyPem, certPem []byte) error {
+	opts, err := app.installOptions()
 	if err != nil {
 		return err
 	}
-	app.stdLogger.Println("main: connected to printer")
 
-	// if using https, check if the cert we're trying to install is already in use
-	if !useHttp {
-		app.stdLogger.Println("main: checking current printer cert ...")
-		currCert, err := print.GetCurrentLeafCert()
+	installed, err := installCertAndReset(logger, printerCfg, keyPem, certPem, opts)
+
+	if installed && app.config.stateFilePath != nil && *app.config.stateFilePath != "" {
+		stateErr := recordInstall(*app.config.stateFilePath, printerCfg.Hostname, time.Now())
+		if stateErr != nil {
+			logger.Printf("main: failed to record install in state file (%s)", stateErr)
+		}
+	}
+
+	return err
+}
+
+// keepCurrentCert applies the install options that don't depend on a new
+// cert to a printer whose current cert is kept (and, if expectedCert is not
+// nil, is expected to be serving it). progress is logged to logger.
+func keepCurrentCert(logger *log.Logger, printerCfg printer.Config, expectedCert *x509.Certificate, opts installOptions) error {
+	// still make sure plain http is off (or redirected)
+	if opts.httpsOnlyMode != "" {
+		return enforceHttpsOnly(logger, "main", printerCfg, expectedCert, opts.httpsOnlyMode)
+	}
+
+	return nil
+}
+
+// installCertAndReset connects to the printer specified in printerCfg and
+// installs the key and cert. it then deletes the old cert and resets the
+// printer so it will load the newly installed key/cert. If certPem is nil,
+// the current cert is kept (only the other options are applied). progress is
+// logged to logger. installed is true once the new cert has been activated.
+func installCertAndReset(logger *log.Logger, printerCfg printer.Config, keyPem, certPem []byte, opts installOptions) (installed bool, err error) {
+	// make printer (which includes login)
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return false, err
+	}
+	// close whichever session is current (the printer is reconnected after
+	// the reboot)
+	defer func() { _ = print.Close() }()
+	logger.Println("main: connected to printer")
+	if model := print.DeviceInfo().Model; model != "" {
+		logger.Printf("main: printer model is %s (profile: %s)", model, print.Profile().Name)
+	}
+
+	// apply tls policy (before the cert check, so it is applied even if the
+	// cert is already installed)
+	if opts.tlsPolicy != nil {
+		err = applyTLSPolicy(logger, "main", print, *opts.tlsPolicy)
 		if err != nil {
-			return err
+			return false, err
 		}
+	}
+
+	// keep the current cert?
+	if certPem == nil {
+		return false, keepCurrentCert(logger, printerCfg, nil, opts)
+	}
 
-		// decode leaf cert
-		newCertPemBlock, _ := pem.Decode(certPem)
-		if newCertPemBlock == nil {
-			return errors.New("main: failed to decode new leaf cert pem block")
+	// if using https, check if the cert we're trying to install is already in use
+	if !printerCfg.UseHttp {
+		logger.Println("main: checking current printer cert ...")
+		currCert, err := print.GetCurrentLeafCert()
+		if err != nil {
+			return false, err
 		}
 
-		// parse 1st cert
-		newCert, err := x509.ParseCertificate(newCertPemBlock.Bytes)
+		newCert, err := parseLeafCert(certPem)
 		if err != nil {
-			return fmt.Errorf("failed to parse new leaf certificate (%s)", err)
+			return false, err
 		}
 
 		if bytes.Equal(currCert.SerialNumber.Bytes(), newCert.SerialNumber.Bytes()) {
-			app.stdLogger.Println("main: current printer certificate and new certificate to upload are the same, aborting")
-			return nil
+			logger.Println("main: current printer certificate and new certificate to upload are the same, not installing")
+			return false, keepCurrentCert(logger, printerCfg, newCert, opts)
 		}
 	} else {
-		app.stdLogger.Println("main: skipping check of current printer cert (--http flag was set)")
+		logger.Println("main: skipping check of current printer cert (--http flag was set)")
 	}
 
 	// get current ssl cert id
 	oldCertId, oldCertName, err := print.GetCurrentCertID()
 	if err != nil {
-		return err
+		return false, err
 	}
-	app.stdLogger.Printf("main: current printer cert is %s (id: %s)", oldCertName, oldCertId)
+	logger.Printf("main: current printer cert is %s (id: %s)", oldCertName, oldCertId)
 
 	// install new key/cert
-	app.stdLogger.Println("main: uploading new cert...")
-	newCertId, err := print.UploadNewCert(keyPem, certPem)
+	logger.Println("main: uploading new cert...")
+	newCertId, err := uploadCert(logger, "main", print, opts.stateFilePath, printerCfg.Hostname, keyPem, certPem)
 	if err != nil {
-		return err
+		return false, err
+	}
+	logger.Printf("main: new printer cert installed (but not yet activated) (id: %s)", newCertId)
+
+	// after the reboot, only use https if it is known to be on for the web ui
+	useHttps := !printerCfg.UseHttp
+	if opts.activate.HttpsWebUI != nil {
+		useHttps = *opts.activate.HttpsWebUI
+	} else if !useHttps {
+		httpSettings, err := print.GetHttpSettings()
+		if err != nil {
+			logger.Printf("main: failed to read http settings, will reconnect over http (%s)", err)
+		} else {
+			useHttps = httpSettings.HttpsWebUI
+		}
 	}
-	app.stdLogger.Printf("main: new printer cert installed (but not yet activated) (id: %s)", newCertId)
 
 	// activate new key/cert
-	app.stdLogger.Printf("main: activating cert (id: %s) and rebooting... please wait 60 seconds...", newCertId)
-	err = print.SetActiveCert(newCertId)
+	logger.Printf("main: activating cert (id: %s) and rebooting... please wait %s...", newCertId, print.RebootWait())
+	err = print.SetActiveCert(newCertId, opts.activate)
 	if err != nil {
-		return err
+		// don't leave the uploaded cert behind
+		return false, print.RollBackUpload(newCertId, err)
 	}
 
 	// IF deleting old cert (i.e. old id != 0 (0 cant be deleted, its "Preset"))
 	if oldCertId != "0" {
 		// wait for reboot to finish
-		time.Sleep(60 * time.Second)
-		app.stdLogger.Printf("main: reboot should be complete")
+		time.Sleep(print.RebootWait())
+		logger.Printf("main: reboot should be complete")
 
-		// use https now (even if user originally said not to, since cert is installed)
-		printerCfg.UseHttp = false
+		// use https now (even if user originally said not to, since cert is installed),
+		// if https for the web ui is on
+		printerCfg.UseHttp = !useHttps
 
 		// must login again due to the restart
 		print, err = printer.NewPrinter(printerCfg)
 		if err != nil {
-			return errors.New("main: failed to reconnect to printer")
+			return true, fmt.Errorf("main: failed to reconnect to printer (%w)", err)
 		}
-		app.stdLogger.Println("main: reconnected to printer")
+		logger.Println("main: reconnected to printer")
 
 		// do delete of old cert
-		app.stdLogger.Printf("main: deleting old cert (id: %s) ...", oldCertId)
-		err = print.DeleteCert(oldCertId)
+		logger.Printf("main: deleting old cert (id: %s) ...", oldCertId)
+		err = deleteOldCert(logger, "main", print, opts.stateFilePath, printerCfg.Hostname, oldCertId)
 		if err != nil {
-			return fmt.Errorf("main: failed to delete cert (id: %s) (%w)", oldCertId, err)
+			return true, fmt.Errorf("main: failed to delete cert (id: %s) (%w)", oldCertId, err)
 		}
 
-		app.stdLogger.Printf("main: old cert (id: %s) deleted", oldCertId)
+		logger.Printf("main: old cert (id: %s) deleted", oldCertId)
 	}
 
-	return nil
+	// turn off (or redirect) plain http
+	if opts.httpsOnlyMode != "" {
+		// wait for reboot to finish (if it wasn't already)
+		if oldCertId == "0" {
+			time.Sleep(print.RebootWait())
+			logger.Printf("main: reboot should be complete")
+		}
+
+		newCert, err := parseLeafCert(certPem)
+		if err != nil {
+			return true, err
+		}
+
+		err = enforceHttpsOnly(logger, "main", printerCfg, newCert, opts.httpsOnlyMode)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
 }
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// length of generated passwords (brother printers allow up to 32 characters)
+const generatedPasswordLength = 20
+
+// passwordSetCfg contains the config options for the password set subcommand
+type passwordSetCfg struct {
+	newPassword         *string
+	newPasswordFilePath *string
+	generate            *bool
+	storeCommand        *string
+}
+
+// generatePassword returns a new random alphanumeric password
+func generatePassword() (string, error) {
+	const chars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+	var b strings.Builder
+	for range generatedPasswordLength {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(chars[i.Int64()])
+	}
+
+	return b.String(), nil
+}
+
+// runPasswordStoreCommand runs the credential helper command to save the new
+// password for hostname. The password is written to the command's stdin.
+func runPasswordStoreCommand(cmdLine, printerName, hostname, password string) error {
+	cmd := shellCommand(cmdLine)
+	cmd.Stdin = strings.NewReader(password + "\n")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"BROTHER_CERT_STORE_PRINTER="+printerName,
+		"BROTHER_CERT_STORE_HOSTNAME="+hostname,
+	)
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("password store command failed (%w)", err)
+	}
+
+	return nil
+}
+
+// setPrinterPassword changes one printer's password to newPassword, confirms
+// the change by logging in again, and then stores it (if configured). A
+// generated password is stored as soon as the printer accepted it (before
+// confirming) instead, since it can't be recovered if it is active but the
+// confirmation fails. A refused password is never stored.
+func (app *app) setPrinterPassword(name string, printerCfg printer.Config, newPassword string) error {
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return err
+	}
+	defer print.Close()
+	app.stdLogger.Printf("password set: connected to %s", name)
+
+	err = print.SetAdminPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	app.stdLogger.Printf("password set: password changed on %s", name)
+
+	// the printer accepted the new password (SetAdminPassword fails if it
+	// shows the form again)
+	generated := *app.config.passwordSet.generate
+	if generated {
+		err = app.storePrinterPassword(name, printerCfg.Hostname, newPassword)
+		if err != nil {
+			return err
+		}
+	}
+
+	// confirm by logging in with the new password (a cached session would
+	// skip the login)
+	app.stdLogger.Printf("password set: confirming new password on %s...", name)
+	printerCfg.Password = newPassword
+	printerCfg.SessionCachePath = ""
+	confirmPrint, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return fmt.Errorf("password set: login with new password failed, the password may or may not have been changed (%w)", err)
+	}
+	_ = confirmPrint.Close()
+	app.stdLogger.Printf("password set: new password confirmed on %s", name)
+
+	if !generated {
+		return app.storePrinterPassword(name, printerCfg.Hostname, newPassword)
+	}
+
+	return nil
+}
+
+// storePrinterPassword runs the store command (if configured) to save the
+// printer's new password. If a generated password can't be stored, it is
+// printed to stderr so that it isn't lost.
+func (app *app) storePrinterPassword(name, hostname, newPassword string) error {
+	if *app.config.passwordSet.storeCommand == "" {
+		return nil
+	}
+
+	err := runPasswordStoreCommand(*app.config.passwordSet.storeCommand, name, hostname, newPassword)
+	if err != nil {
+		if *app.config.passwordSet.generate {
+			fmt.Fprintf(os.Stderr, "password set: new password for %s (not stored): %s\n", name, newPassword)
+		}
+		return fmt.Errorf("password set: new password was set but was not stored (%w)", err)
+	}
+	app.stdLogger.Printf("password set: new password for %s stored", name)
+
+	return nil
+}
+
+// cmdPasswordSet changes the administrator password of one printer or of all
+// printers in the inventory
+func (app *app) cmdPasswordSet(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("password set: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	cfg := app.config.passwordSet
+
+	// exactly one new password source
+	sources := 0
+	for _, specified := range []bool{*cfg.newPassword != "", *cfg.newPasswordFilePath != "", *cfg.generate} {
+		if specified {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("password set: exactly one of new password, new password file, or generate must be specified")
+	}
+
+	// a generated password would be lost if it isn't stored
+	if *cfg.generate && *cfg.storeCommand == "" {
+		return errors.New("password set: store command must be specified when generating passwords")
+	}
+
+	newPassword := *cfg.newPassword
+	if *cfg.newPasswordFilePath != "" {
+		fileBytes, err := os.ReadFile(*cfg.newPasswordFilePath)
+		if err != nil {
+			return fmt.Errorf("password set: failed to read new password file (%w)", err)
+		}
+		newPassword = trimPassword(string(fileBytes))
+	}
+
+	names, printerCfgs, err := app.loginTargets("password set")
+	if err != nil {
+		return err
+	}
+
+	if *app.config.inventoryPath != "" && *cfg.storeCommand == "" {
+		app.stdLogger.Println("WARNING: no store command specified, the inventory file must be updated with the new password(s)")
+	}
+
+	// change each printer (one failure shouldn't stop the rest)
+	var errs []error
+	for i := range printerCfgs {
+		printerNewPassword := newPassword
+		if *cfg.generate {
+			printerNewPassword, err = generatePassword()
+			if err != nil {
+				return fmt.Errorf("password set: failed to generate password (%w)", err)
+			}
+		}
+
+		err = app.setPrinterPassword(names[i], printerCfgs[i], printerNewPassword)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
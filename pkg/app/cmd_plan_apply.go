@@ -0,0 +1,415 @@
+package app
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// loadDesiredStateArg loads and resolves the desired state file specified in
+// args (which must be the only arg)
+func (app *app) loadDesiredStateArg(subcommand string, args []string) (*resolvedState, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: desired state file must be specified", subcommand)
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("%s: failed, %w (%d)", subcommand, ErrExtraArgs, len(args)-1)
+	}
+
+	state, err := loadDesiredState(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", subcommand, err)
+	}
+
+	resolved, err := app.resolveDesiredState(state)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", subcommand, err)
+	}
+
+	return resolved, nil
+}
+
+// printPlan prints the changes apply would make to one printer
+func (app *app) printPlan(name string, printerCfg printer.Config, state *resolvedState) error {
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return err
+	}
+	defer print.Close()
+
+	printerState, err := app.planDesiredCert("plan", state, printerCfg)
+	if err != nil {
+		return err
+	}
+
+	plan, err := planState(print, printerState)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.changes) == 0 {
+		fmt.Printf("%s: no changes\n", name)
+		return nil
+	}
+
+	restart := ""
+	if plan.needsRestart() {
+		restart = ", 1 restart"
+	}
+	fmt.Printf("%s: %d change(s)%s\n", name, len(plan.changes), restart)
+	for _, c := range plan.changes {
+		fmt.Printf("  %s\n", c)
+	}
+
+	return nil
+}
+
+// cmdPlan shows the changes needed to bring the printer (or all printers in
+// the inventory) to the desired state. Nothing is changed (and no cert is
+// issued).
+func (app *app) cmdPlan(_ context.Context, args []string) error {
+	state, err := app.loadDesiredStateArg("plan", args)
+	if err != nil {
+		return err
+	}
+
+	names, printerCfgs, err := app.loginTargets("plan")
+	if err != nil {
+		return err
+	}
+
+	// one failure shouldn't stop the rest
+	var errs []error
+	for i := range printerCfgs {
+		err = app.printPlan(names[i], printerCfgs[i], state)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// verifyCertForHostname returns an error if the leaf cert in certPem is not
+// valid for hostname or does not chain to roots (nil roots uses the system
+// roots)
+func verifyCertForHostname(certPem []byte, hostname string, roots *x509.CertPool) error {
+	certs := []*x509.Certificate{}
+	rest := certPem
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return errors.New("no certificate found")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       hostname,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// protocolPrinter is a printer whose protocol settings can be read
+type protocolPrinter interface {
+	GetProtocolSettings() (map[string]bool, error)
+}
+
+// checkProtocols returns an error unless the printer's protocols (by case
+// insensitive label) are in the state protocols
+func checkProtocols(print protocolPrinter, protocols map[string]bool) error {
+	current, err := print.GetProtocolSettings()
+	if err != nil {
+		return fmt.Errorf("apply: failed to read back protocol settings (%w)", err)
+	}
+
+	for protocol, enabled := range protocols {
+		for label, currEnabled := range current {
+			if strings.EqualFold(label, protocol) && currEnabled != enabled {
+				return fmt.Errorf("apply: protocol '%s' is still %s after applying", label, enabledString(currEnabled))
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyState brings one printer to the desired state, making only the needed
+// changes. Changes that require a restart are made with a single restart,
+// unless plain http is being turned off along with a new cert that can't be
+// verified locally (then http is changed after the new cert is confirmed, so
+// the web ui can't be locked out).
+func (app *app) applyState(name string, printerCfg printer.Config, state *resolvedState) error {
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return err
+	}
+	// close whichever session is current (the printer is reconnected after
+	// restarts)
+	defer func() { _ = print.Close() }()
+	app.stdLogger.Printf("apply: connected to %s", name)
+
+	// only issue a cert for a printer that can be reached and logged in to
+	state, err = app.issueDesiredCert("apply", state, printerCfg)
+	if err != nil {
+		return err
+	}
+
+	plan, err := planState(print, state)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.changes) == 0 {
+		app.stdLogger.Printf("apply: %s: no changes", name)
+		return nil
+	}
+	for _, c := range plan.changes {
+		app.stdLogger.Printf("apply: %s: %s", name, c)
+	}
+
+	// changes that don't need a restart
+	for _, ca := range plan.uploadCAs {
+		caId, err := print.UploadCACert(ca.certPem)
+		if err != nil {
+			return err
+		}
+		app.stdLogger.Printf("apply: %s: trusted ca %s installed (id: %s)", name, ca.cert.Subject.CommonName, caId)
+	}
+
+	if plan.tls != nil {
+		err = print.SetTLSSettings(*plan.tls)
+		if err != nil {
+			return err
+		}
+		app.stdLogger.Printf("apply: %s: tls settings updated", name)
+	}
+
+	// protocol settings are activated by the http server settings restart if
+	// there is one, otherwise they restart the printer themselves (if it asks)
+	httpRestart := plan.installCert || plan.http != nil
+	protocolsPending := false
+	if plan.protocols != nil {
+		restartAsked, err := print.SetProtocolSettings(plan.protocols, !httpRestart)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case restartAsked && httpRestart:
+			protocolsPending = true
+			app.stdLogger.Printf("apply: %s: protocol settings saved (activated by the restart below)", name)
+
+		case restartAsked:
+			app.stdLogger.Printf("apply: %s: protocol settings saved, rebooting... please wait %s...", name, print.RebootWait())
+			time.Sleep(print.RebootWait())
+
+			print, err = printer.NewPrinter(printerCfg)
+			if err != nil {
+				return fmt.Errorf("apply: failed to reconnect to printer (%w)", err)
+			}
+			app.stdLogger.Printf("apply: reconnected to %s", name)
+
+			err = checkProtocols(print, plan.protocols)
+			if err != nil {
+				return err
+			}
+			app.stdLogger.Printf("apply: %s: protocol settings updated", name)
+
+		default:
+			app.stdLogger.Printf("apply: %s: protocol settings updated", name)
+		}
+	}
+
+	if !httpRestart {
+		app.stdLogger.Printf("apply: %s: desired state applied", name)
+		return nil
+	}
+
+	// changes that need a restart (all made with one submission of the http
+	// server settings)
+	httpChange := printer.HttpSettingsChange{}
+	if plan.http != nil {
+		httpChange = *plan.http
+	}
+
+	oldCertId := ""
+	if plan.installCert {
+		oldCertId, _, err = print.GetCurrentCertID()
+		if err != nil {
+			return err
+		}
+
+		newCertId, err := uploadCert(app.stdLogger, "apply", print, *app.config.stateFilePath, printerCfg.Hostname, state.keyPem, state.certPem)
+		if err != nil {
+			return err
+		}
+		app.stdLogger.Printf("apply: %s: new cert installed (but not yet activated) (id: %s)", name, newCertId)
+
+		activateOpts, err := app.config.activate.options()
+		if err != nil {
+			return err
+		}
+
+		httpChange.CertID = newCertId
+		httpChange.ActivateOtherProtocols = activateOpts.ActivateOtherProtocols
+	}
+
+	// confirm https will work before turning off plain http
+	var deferredHttpChange *printer.HttpSettingsChange
+	plainHttpOff := (httpChange.PlainHttp != nil && !*httpChange.PlainHttp) ||
+		(httpChange.RedirectHttp != nil && *httpChange.RedirectHttp)
+	if plainHttpOff {
+		if plan.installCert {
+			err = verifyCertForHostname(state.certPem, printerCfg.Hostname, printerCfg.RootCAs)
+			if err != nil {
+				app.stdLogger.Printf("apply: %s: new cert could not be verified locally (%s), http will be changed after the new cert is confirmed (2 restarts)", name, err)
+				deferredHttpChange = &printer.HttpSettingsChange{
+					PlainHttp:    httpChange.PlainHttp,
+					RedirectHttp: httpChange.RedirectHttp,
+				}
+				httpChange.PlainHttp = nil
+				httpChange.RedirectHttp = nil
+			}
+		} else {
+			httpsCfg := printerCfg
+			httpsCfg.UseHttp = false
+			httpsPrint, err := printer.NewPrinter(httpsCfg)
+			if err != nil {
+				return fmt.Errorf("apply: failed to connect to printer over https, not changing http (%w)", err)
+			}
+			_ = httpsPrint.Close()
+		}
+	}
+
+	// reconnect the same way, unless that way is being turned off
+	reconnectCfg := printerCfg
+	if plainHttpOff {
+		reconnectCfg.UseHttp = false
+	}
+	if httpChange.HttpsWebUI != nil && !*httpChange.HttpsWebUI {
+		reconnectCfg.UseHttp = true
+	}
+
+	app.stdLogger.Printf("apply: %s: changing http server settings and rebooting... please wait %s...", name, print.RebootWait())
+	err = print.ChangeHttpSettings(httpChange)
+	if err != nil {
+		// don't leave the uploaded cert behind
+		if plan.installCert {
+			return print.RollBackUpload(httpChange.CertID, err)
+		}
+		return err
+	}
+	time.Sleep(print.RebootWait())
+
+	print, err = printer.NewPrinter(reconnectCfg)
+	if err != nil {
+		return fmt.Errorf("apply: failed to reconnect to printer (%w)", err)
+	}
+	app.stdLogger.Printf("apply: reconnected to %s", name)
+
+	if protocolsPending {
+		err = checkProtocols(print, plan.protocols)
+		if err != nil {
+			return err
+		}
+		app.stdLogger.Printf("apply: %s: protocol settings updated", name)
+	}
+
+	if plan.installCert {
+		httpsCfg := printerCfg
+		httpsCfg.UseHttp = false
+		err = verifyServedCert(httpsCfg, state.leafCert, nil)
+		if err != nil {
+			return fmt.Errorf("apply: new cert verification failed (%w)", err)
+		}
+		app.stdLogger.Printf("apply: %s: printer is serving the new cert", name)
+
+		if app.config.stateFilePath != nil && *app.config.stateFilePath != "" {
+			err = recordInstall(*app.config.stateFilePath, printerCfg.Hostname, time.Now())
+			if err != nil {
+				app.stdLogger.Printf("apply: failed to record install in state file (%s)", err)
+			}
+		}
+
+		// 0 can't be deleted, its "Preset"
+		if oldCertId != "0" {
+			err = deleteOldCert(app.stdLogger, "apply", print, *app.config.stateFilePath, printerCfg.Hostname, oldCertId)
+			if err != nil {
+				return fmt.Errorf("apply: failed to delete old cert (id: %s) (%w)", oldCertId, err)
+			}
+			app.stdLogger.Printf("apply: %s: old cert (id: %s) deleted", name, oldCertId)
+		}
+	}
+
+	if deferredHttpChange != nil {
+		reconnectCfg.UseHttp = false
+		print, err = printer.NewPrinter(reconnectCfg)
+		if err != nil {
+			return fmt.Errorf("apply: failed to connect to printer over https, not changing http (%w)", err)
+		}
+
+		app.stdLogger.Printf("apply: %s: changing http and rebooting... please wait %s...", name, print.RebootWait())
+		err = print.ChangeHttpSettings(*deferredHttpChange)
+		if err != nil {
+			return err
+		}
+		time.Sleep(print.RebootWait())
+
+		print, err = printer.NewPrinter(reconnectCfg)
+		if err != nil {
+			return fmt.Errorf("apply: failed to reconnect to printer over https after changing http (%w)", err)
+		}
+	}
+
+	app.stdLogger.Printf("apply: %s: desired state applied", name)
+	return nil
+}
+
+// cmdApply brings the printer (or all printers in the inventory) to the
+// desired state
+func (app *app) cmdApply(_ context.Context, args []string) error {
+	state, err := app.loadDesiredStateArg("apply", args)
+	if err != nil {
+		return err
+	}
+
+	names, printerCfgs, err := app.loginTargets("apply")
+	if err != nil {
+		return err
+	}
+
+	// one failure shouldn't stop the rest
+	var errs []error
+	for i := range printerCfgs {
+		err = app.applyState(names[i], printerCfgs[i], state)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
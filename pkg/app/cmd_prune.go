@@ -0,0 +1,301 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// pruneCfg contains the config options for the prune subcommand
+type pruneCfg struct {
+	keep *int
+}
+
+// certStorePrinter is a printer whose stored certs can be listed and deleted
+type certStorePrinter interface {
+	ListCerts() ([]printer.CertInfo, error)
+	DeleteCert(id string) error
+	Profile() printer.Profile
+}
+
+// serialHex returns serial as the hex string used in the state file
+func serialHex(serial []byte) string {
+	return fmt.Sprintf("%x", bytes.TrimLeft(serial, "\x00"))
+}
+
+// pruneCandidates returns the certs that may be pruned, oldest expiry first
+// (unknown expiry last): certs this tool uploaded (by serial in managed) that
+// aren't active or the Preset. Since the active cert must never be pruned,
+// nothing may be pruned if the active cert isn't known.
+func pruneCandidates(certs []printer.CertInfo, managed []string) ([]printer.CertInfo, error) {
+	managedSerials := make(map[string]struct{})
+	for _, serial := range managed {
+		managedSerials[serial] = struct{}{}
+	}
+
+	activeKnown := false
+	candidates := []printer.CertInfo{}
+	for _, cert := range certs {
+		if cert.Active {
+			activeKnown = true
+			continue
+		}
+
+		// 0 can't be deleted, its "Preset"
+		if cert.ID == "0" {
+			continue
+		}
+
+		if _, isManaged := managedSerials[serialHex(cert.Serial)]; !isManaged {
+			continue
+		}
+
+		candidates = append(candidates, cert)
+	}
+
+	if !activeKnown {
+		return nil, errors.New("can't determine which cert is active, not pruning")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].NotAfter.IsZero() != candidates[j].NotAfter.IsZero() {
+			return !candidates[i].NotAfter.IsZero()
+		}
+		return candidates[i].NotAfter.Before(candidates[j].NotAfter)
+	})
+
+	return candidates, nil
+}
+
+// deleteCerts deletes certs from the printer (hostname) and removes them from
+// the state file at statePath. progress is logged to logger.
+func deleteCerts(logger *log.Logger, subcommand string, print certStorePrinter, statePath, hostname string, certs []printer.CertInfo) error {
+	for _, cert := range certs {
+		expires := "unknown"
+		if !cert.NotAfter.IsZero() {
+			expires = cert.NotAfter.Format("2006-01-02")
+		}
+
+		logger.Printf("%s: pruning cert %s (id: %s, expires: %s) ...", subcommand, cert.Name, cert.ID, expires)
+		err := print.DeleteCert(cert.ID)
+		if err != nil {
+			return fmt.Errorf("%s: failed to prune cert (id: %s) (%w)", subcommand, cert.ID, err)
+		}
+
+		err = forgetManagedCert(statePath, hostname, serialHex(cert.Serial))
+		if err != nil {
+			logger.Printf("%s: failed to update state file (%s)", subcommand, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteOldCert deletes the cert id (e.g. the cert an install replaced) from
+// the printer (hostname) and, if statePath is set, removes it from the state
+// file. progress is logged to logger.
+func deleteOldCert(logger *log.Logger, subcommand string, print certStorePrinter, statePath, hostname, id string) error {
+	// the state file records serials, so find the cert's before deleting it
+	serial := ""
+	if statePath != "" {
+		certs, err := print.ListCerts()
+		if err != nil {
+			logger.Printf("%s: failed to list certs, the old cert won't be removed from the state file (%s)", subcommand, err)
+		}
+		for _, cert := range certs {
+			if cert.ID == id {
+				serial = serialHex(cert.Serial)
+				break
+			}
+		}
+	}
+
+	err := print.DeleteCert(id)
+	if err != nil {
+		return err
+	}
+
+	if serial != "" {
+		err = forgetManagedCert(statePath, hostname, serial)
+		if err != nil {
+			logger.Printf("%s: failed to update state file (%s)", subcommand, err)
+		}
+	}
+
+	return nil
+}
+
+// makeRoomForCert prunes old certs this tool uploaded to the printer
+// (hostname) so there is room to upload one more. If storeFull is false, the
+// store is only known to be full if the printer's profile has a cert limit,
+// and failing to make room isn't an error (the upload may still work).
+// progress is logged to logger.
+func makeRoomForCert(logger *log.Logger, subcommand string, print certStorePrinter, statePath, hostname string, storeFull bool) error {
+	limit := print.Profile().CertLimit
+	if !storeFull && limit <= 0 {
+		return nil
+	}
+
+	certs, err := print.ListCerts()
+	if err != nil {
+		return err
+	}
+
+	need := 1
+	if !storeFull {
+		need = len(certs) - limit + 1
+		if need <= 0 {
+			return nil
+		}
+		logger.Printf("%s: printer is storing %d of %d certs, making room for the new cert", subcommand, len(certs), limit)
+	}
+
+	state, err := loadInstallState(statePath)
+	if err != nil {
+		return err
+	}
+
+	candidates, err := pruneCandidates(certs, state.ManagedCerts[hostname])
+	if err == nil && len(candidates) <= 0 {
+		err = errors.New("there are no old certs uploaded by this tool to prune")
+	}
+	if err != nil {
+		if !storeFull {
+			logger.Printf("%s: can't make room for the new cert, uploading anyway (%s)", subcommand, err)
+			return nil
+		}
+		return fmt.Errorf("%s: %w (%w)", subcommand, printer.ErrStorageFull, err)
+	}
+	if len(candidates) < need {
+		logger.Printf("%s: only %d old cert(s) uploaded by this tool can be pruned (%d needed)", subcommand, len(candidates), need)
+		need = len(candidates)
+	}
+
+	return deleteCerts(logger, subcommand, print, statePath, hostname, candidates[:need])
+}
+
+// certUploadPrinter is a printer that certs can be uploaded to (and pruned
+// from)
+type certUploadPrinter interface {
+	certStorePrinter
+	UploadNewCert(keyPem, certPem []byte) (string, error)
+}
+
+// uploadCert uploads the key/cert to the printer (hostname) and returns the
+// new cert's id. If statePath is set, old certs this tool uploaded are pruned
+// first if the printer's store is at its limit (or the upload fails because
+// the store is full), and the new cert is recorded as uploaded by this tool.
+// progress is logged to logger.
+func uploadCert(logger *log.Logger, subcommand string, print certUploadPrinter, statePath, hostname string, keyPem, certPem []byte) (string, error) {
+	if statePath == "" {
+		return print.UploadNewCert(keyPem, certPem)
+	}
+
+	newCert, err := parseLeafCert(certPem)
+	if err != nil {
+		return "", err
+	}
+
+	err = makeRoomForCert(logger, subcommand, print, statePath, hostname, false)
+	if err != nil {
+		return "", err
+	}
+
+	newCertId, err := print.UploadNewCert(keyPem, certPem)
+	if errors.Is(err, printer.ErrStorageFull) {
+		logger.Printf("%s: printer's cert storage is full, pruning and trying again (%s)", subcommand, err)
+		err = makeRoomForCert(logger, subcommand, print, statePath, hostname, true)
+		if err != nil {
+			return "", err
+		}
+
+		newCertId, err = print.UploadNewCert(keyPem, certPem)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// record before activating, so a cert left behind by an interrupted run
+	// can be pruned
+	err = recordManagedCert(statePath, hostname, serialHex(newCert.SerialNumber.Bytes()))
+	if err != nil {
+		logger.Printf("%s: failed to record the new cert in the state file (%s)", subcommand, err)
+	}
+
+	return newCertId, nil
+}
+
+// prunePrinter deletes the certs this tool uploaded to the printer (hostname)
+// that aren't active, except for the keep newest. progress is logged to
+// logger.
+func prunePrinter(logger *log.Logger, print certStorePrinter, statePath, hostname string, keep int) error {
+	certs, err := print.ListCerts()
+	if err != nil {
+		return err
+	}
+
+	state, err := loadInstallState(statePath)
+	if err != nil {
+		return err
+	}
+
+	candidates, err := pruneCandidates(certs, state.ManagedCerts[hostname])
+	if err != nil {
+		return fmt.Errorf("prune: %w", err)
+	}
+	if len(candidates) <= keep {
+		logger.Printf("prune: %s: nothing to prune (%d old cert(s) uploaded by this tool, keeping %d)", hostname, len(candidates), keep)
+		return nil
+	}
+
+	// candidates are oldest first
+	return deleteCerts(logger, "prune", print, statePath, hostname, candidates[:len(candidates)-keep])
+}
+
+// cmdPrune deletes old, inactive certs this tool uploaded from the printer
+// (or all printers in the inventory), e.g. certs left behind by interrupted
+// installs
+func (app *app) cmdPrune(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("prune: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	if app.config.stateFilePath == nil || *app.config.stateFilePath == "" {
+		return errors.New("prune: state file must be specified (it records which certs this tool uploaded)")
+	}
+
+	if *app.config.prune.keep < 0 {
+		return errors.New("prune: keep can't be negative")
+	}
+
+	names, printerCfgs, err := app.loginTargets("prune")
+	if err != nil {
+		return err
+	}
+
+	// one failure shouldn't stop the rest
+	var errs []error
+	for i := range printerCfgs {
+		print, err := printer.NewPrinter(printerCfgs[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+		app.stdLogger.Printf("prune: connected to %s", names[i])
+
+		err = prunePrinter(app.stdLogger, print, *app.config.stateFilePath, printerCfgs[i].Hostname, *app.config.prune.keep)
+		_ = print.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+	}
+
+	return errors.Join(errs...)
+}
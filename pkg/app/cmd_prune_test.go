@@ -0,0 +1,236 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// fakeCertStore is a certStorePrinter that stores certs in memory
+type fakeCertStore struct {
+	certs   []printer.CertInfo
+	limit   int
+	deleted []string
+}
+
+func (f *fakeCertStore) ListCerts() ([]printer.CertInfo, error) {
+	return slices.Clone(f.certs), nil
+}
+
+func (f *fakeCertStore) DeleteCert(id string) error {
+	for i, cert := range f.certs {
+		if cert.ID == id {
+			if cert.Active || id == "0" {
+				return errors.New("fake printer: can't delete the active cert or the preset")
+			}
+			f.certs = slices.Delete(f.certs, i, i+1)
+			f.deleted = append(f.deleted, id)
+			return nil
+		}
+	}
+
+	return errors.New("fake printer: cert not found")
+}
+
+func (f *fakeCertStore) Profile() printer.Profile {
+	return printer.Profile{CertLimit: f.limit}
+}
+
+// testStoredCerts returns the certs of a printer with the Preset, an active
+// cert, and inactive certs: 2 (expires in 60 days), 3 (30 days), 4 (not
+// uploaded by this tool), and 5 (unknown expiry). All but 4 are managed.
+func testStoredCerts() (certs []printer.CertInfo, managed []string) {
+	now := time.Now()
+	certs = []printer.CertInfo{
+		{ID: "0", Name: "Preset", Serial: []byte{0x10}, NotAfter: now.Add(10 * 24 * time.Hour)},
+		{ID: "1", Name: "active", Serial: []byte{0x11}, NotAfter: now.Add(5 * 24 * time.Hour), Active: true},
+		{ID: "2", Name: "cert2", Serial: []byte{0x12}, NotAfter: now.Add(60 * 24 * time.Hour)},
+		{ID: "3", Name: "cert3", Serial: []byte{0x13}, NotAfter: now.Add(30 * 24 * time.Hour)},
+		{ID: "4", Name: "unmanaged", Serial: []byte{0x14}, NotAfter: now.Add(1 * 24 * time.Hour)},
+		{ID: "5", Name: "cert5", Serial: []byte{0x15}},
+	}
+
+	// the Preset and the active cert are recorded too, they must still never
+	// be pruned
+	managed = []string{"10", "11", "12", "13", "15"}
+
+	return certs, managed
+}
+
+// certIDs returns the ids of certs
+func certIDs(certs []printer.CertInfo) []string {
+	ids := []string{}
+	for _, cert := range certs {
+		ids = append(ids, cert.ID)
+	}
+
+	return ids
+}
+
+// newTestStateFile returns the path of a state file (in a temp dir) that
+// records managed as the certs uploaded to hostname
+func newTestStateFile(t *testing.T, hostname string, managed []string) string {
+	t.Helper()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	for _, serial := range managed {
+		err := recordManagedCert(statePath, hostname, serial)
+		if err != nil {
+			t.Fatalf("failed to record managed cert (%s)", err)
+		}
+	}
+
+	return statePath
+}
+
+func TestPruneCandidates(t *testing.T) {
+	certs, managed := testStoredCerts()
+
+	got, err := pruneCandidates(certs, managed)
+	if err != nil {
+		t.Fatalf("unexpected error (%s)", err)
+	}
+
+	// oldest expiry first, unknown expiry last; never the active cert, the
+	// Preset, or a cert this tool didn't upload
+	want := []string{"3", "2", "5"}
+	if !slices.Equal(certIDs(got), want) {
+		t.Errorf("got %v, want %v", certIDs(got), want)
+	}
+}
+
+func TestPruneCandidatesActiveUnknown(t *testing.T) {
+	certs, managed := testStoredCerts()
+	for i := range certs {
+		certs[i].Active = false
+	}
+
+	got, err := pruneCandidates(certs, managed)
+	if err == nil {
+		t.Fatalf("expected error, got candidates %v", certIDs(got))
+	}
+}
+
+func TestPrunePrinter(t *testing.T) {
+	const hostname = "printer.example.com"
+
+	tests := []struct {
+		name        string
+		keep        int
+		wantDeleted []string
+	}{
+		{name: "keep none", keep: 0, wantDeleted: []string{"3", "2", "5"}},
+		{name: "keep newest", keep: 1, wantDeleted: []string{"3", "2"}},
+		{name: "keep all", keep: 3, wantDeleted: nil},
+		{name: "keep more than stored", keep: 5, wantDeleted: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, managed := testStoredCerts()
+			print := &fakeCertStore{certs: slices.Clone(certs)}
+			statePath := newTestStateFile(t, hostname, managed)
+
+			err := prunePrinter(log.New(io.Discard, "", 0), print, statePath, hostname, tt.keep)
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			if !slices.Equal(print.deleted, tt.wantDeleted) {
+				t.Errorf("deleted %v, want %v", print.deleted, tt.wantDeleted)
+			}
+
+			// deleted certs are removed from the state file
+			state, err := loadInstallState(statePath)
+			if err != nil {
+				t.Fatalf("failed to load state file (%s)", err)
+			}
+			for _, cert := range certs {
+				if !slices.Contains(print.deleted, cert.ID) {
+					continue
+				}
+				if slices.Contains(state.ManagedCerts[hostname], serialHex(cert.Serial)) {
+					t.Errorf("deleted cert %s is still in the state file", cert.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestPrunePrinterActiveUnknown(t *testing.T) {
+	const hostname = "printer.example.com"
+
+	certs, managed := testStoredCerts()
+	for i := range certs {
+		certs[i].Active = false
+	}
+	print := &fakeCertStore{certs: slices.Clone(certs)}
+	statePath := newTestStateFile(t, hostname, managed)
+
+	err := prunePrinter(log.New(io.Discard, "", 0), print, statePath, hostname, 0)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(print.deleted) > 0 {
+		t.Errorf("deleted %v, want none", print.deleted)
+	}
+}
+
+func TestMakeRoomForCert(t *testing.T) {
+	const hostname = "printer.example.com"
+
+	tests := []struct {
+		name          string
+		limit         int
+		storeFull     bool
+		activeUnknown bool
+		noManaged     bool
+		wantDeleted   []string
+		wantFullErr   bool
+	}{
+		{name: "no limit", limit: 0, wantDeleted: nil},
+		{name: "below limit", limit: 7, wantDeleted: nil},
+		{name: "at limit", limit: 6, wantDeleted: []string{"3"}},
+		{name: "over limit", limit: 5, wantDeleted: []string{"3", "2"}},
+		{name: "over limit by more than can be pruned", limit: 2, wantDeleted: []string{"3", "2", "5"}},
+		{name: "store full without limit", limit: 0, storeFull: true, wantDeleted: []string{"3"}},
+		{name: "at limit with active unknown", limit: 6, activeUnknown: true, wantDeleted: nil},
+		{name: "store full with active unknown", storeFull: true, activeUnknown: true, wantFullErr: true},
+		{name: "store full with nothing to prune", storeFull: true, noManaged: true, wantFullErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, managed := testStoredCerts()
+			if tt.activeUnknown {
+				for i := range certs {
+					certs[i].Active = false
+				}
+			}
+			if tt.noManaged {
+				managed = nil
+			}
+			print := &fakeCertStore{certs: slices.Clone(certs), limit: tt.limit}
+			statePath := newTestStateFile(t, hostname, managed)
+
+			err := makeRoomForCert(log.New(io.Discard, "", 0), "test", print, statePath, hostname, tt.storeFull)
+			if tt.wantFullErr {
+				if !errors.Is(err, printer.ErrStorageFull) {
+					t.Fatalf("got error %v, want storage full", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			if !slices.Equal(print.deleted, tt.wantDeleted) {
+				t.Errorf("deleted %v, want %v", print.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
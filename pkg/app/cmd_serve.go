@@ -0,0 +1,403 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// how long finished jobs are kept in memory
+const apiJobRetention = 24 * time.Hour
+
+// serveCfg contains the config options for the serve subcommand
+type serveCfg struct {
+	listenAddress      *string
+	serverKeyFilePath  *string
+	serverCertFilePath *string
+	apiKey             *string
+	clientCAFilePath   *string
+}
+
+// api job states
+const (
+	apiJobQueued    = "queued"
+	apiJobRunning   = "running"
+	apiJobSucceeded = "succeeded"
+	apiJobFailed    = "failed"
+)
+
+// apiJob is an asynchronous install run by the api server
+type apiJob struct {
+	ID       string     `json:"id"`
+	Printer  string     `json:"printer"`
+	Status   string     `json:"status"`
+	Logs     []string   `json:"logs"`
+	Result   string     `json:"result,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// apiServer serves the http api
+type apiServer struct {
+	app *app
+	inv *inventory
+
+	mu   sync.RWMutex
+	jobs map[string]*apiJob
+
+	// printerLocks ensures only one job runs against a printer at a time
+	printerLocks map[string]*sync.Mutex
+
+	// running tracks jobs that haven't finished, so shutdown can wait for
+	// them instead of abandoning a printer mid-install
+	running sync.WaitGroup
+}
+
+// apiJobLogWriter is an io.Writer that appends each write to a job's logs
+type apiJobLogWriter struct {
+	server *apiServer
+	job    *apiJob
+}
+
+func (w *apiJobLogWriter) Write(p []byte) (int, error) {
+	w.server.mu.Lock()
+	defer w.server.mu.Unlock()
+
+	w.job.Logs = append(w.job.Logs, strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// writeJSON writes v as the json response with the specified status code
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error json response
+func writeJSONError(w http.ResponseWriter, statusCode int, msg string) {
+	writeJSON(w, statusCode, map[string]string{"error": msg})
+}
+
+// authenticate wraps next and only allows requests that have a verified client
+// certificate or the correct api key
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// mTLS (only verified certs are in VerifiedChains)
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// api key
+		if *s.app.config.serve.apiKey != "" {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				// only the bearer scheme, not a bare key
+				bearer, isBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if isBearer {
+					key = bearer
+				}
+			}
+
+			if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(*s.app.config.serve.apiKey)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+// printerConfig returns the printer config for the named inventory printer
+func (s *apiServer) printerConfig(name string) (printer.Config, bool) {
+	for _, p := range s.inv.Printers {
+		if p.Name == name {
+			return p.printerConfig(), true
+		}
+	}
+
+	return printer.Config{}, false
+}
+
+// newJob creates a new queued job for the named printer
+func (s *apiServer) newJob(printerName string) (*apiJob, error) {
+	idBytes := make([]byte, 16)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &apiJob{
+		ID:      hex.EncodeToString(idBytes),
+		Printer: printerName,
+		Status:  apiJobQueued,
+		Logs:    []string{},
+		Created: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// drop old finished jobs
+	for id, oldJob := range s.jobs {
+		if oldJob.Finished != nil && time.Since(*oldJob.Finished) > apiJobRetention {
+			delete(s.jobs, id)
+		}
+	}
+
+	s.jobs[job.ID] = job
+
+	return job, nil
+}
+
+// runInstallJob runs the install flow for job
+func (s *apiServer) runInstallJob(job *apiJob, printerCfg printer.Config, keyPem, certPem []byte) {
+	defer s.running.Done()
+
+	// one job per printer at a time
+	s.printerLocks[job.Printer].Lock()
+	defer s.printerLocks[job.Printer].Unlock()
+
+	s.mu.Lock()
+	started := time.Now()
+	job.Started = &started
+	job.Status = apiJobRunning
+	s.mu.Unlock()
+
+	logger := log.New(&apiJobLogWriter{server: s, job: job}, "", 0)
+	err := s.app.installCertAndReset(logger, printerCfg, keyPem, certPem)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		job.Status = apiJobFailed
+		job.Error = err.Error()
+		s.app.errLogger.Printf("serve: job %s (printer %s) failed (%s)", job.ID, job.Printer, err)
+		return
+	}
+
+	job.Status = apiJobSucceeded
+	job.Result = "install complete"
+	s.app.stdLogger.Printf("serve: job %s (printer %s) succeeded", job.ID, job.Printer)
+}
+
+// handleInstall starts an install job for the named printer
+func (s *apiServer) handleInstall(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	printerCfg, found := s.printerConfig(name)
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "printer not found")
+		return
+	}
+
+	// decode payload
+	payload := struct {
+		KeyPem  string `json:"key_pem"`
+		CertPem string `json:"cert_pem"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid payload (%s)", err))
+		return
+	}
+	if payload.KeyPem == "" || payload.CertPem == "" {
+		writeJSONError(w, http.StatusBadRequest, "key_pem and cert_pem are required")
+		return
+	}
+
+	job, err := s.newJob(name)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+
+	s.app.stdLogger.Printf("serve: job %s (printer %s) queued", job.ID, name)
+	s.running.Add(1)
+	go s.runInstallJob(job, printerCfg, []byte(payload.KeyPem), []byte(payload.CertPem))
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"job_id": job.ID})
+}
+
+// handleJob returns the status of a job
+func (s *apiServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, found := s.jobs[r.PathValue("id")]
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleListCerts returns the certificates stored on the named printer
+func (s *apiServer) handleListCerts(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	printerCfg, found := s.printerConfig(name)
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "printer not found")
+		return
+	}
+
+	// don't interfere with a running job
+	s.printerLocks[name].Lock()
+	defer s.printerLocks[name].Unlock()
+
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer print.Close()
+
+	certs, err := print.ListCerts()
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	type apiCert struct {
+		ID        string     `json:"id"`
+		Name      string     `json:"name,omitempty"`
+		Serial    string     `json:"serial"`
+		Issuer    string     `json:"issuer,omitempty"`
+		Subject   string     `json:"subject,omitempty"`
+		NotBefore *time.Time `json:"not_before,omitempty"`
+		NotAfter  *time.Time `json:"not_after,omitempty"`
+		Active    bool       `json:"active"`
+	}
+
+	response := []apiCert{}
+	for _, cert := range certs {
+		apiC := apiCert{
+			ID:      cert.ID,
+			Name:    cert.Name,
+			Serial:  hex.EncodeToString(cert.Serial),
+			Issuer:  cert.Issuer,
+			Subject: cert.Subject,
+			Active:  cert.Active,
+		}
+		if !cert.NotBefore.IsZero() {
+			apiC.NotBefore = &cert.NotBefore
+		}
+		if !cert.NotAfter.IsZero() {
+			apiC.NotAfter = &cert.NotAfter
+		}
+
+		response = append(response, apiC)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"printer": name, "certificates": response})
+}
+
+// cmdServe runs an https api server that can install certs on the printers in
+// the inventory
+func (app *app) cmdServe(ctx context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("serve: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	if app.config.inventoryPath == nil || *app.config.inventoryPath == "" {
+		return errors.New("serve: inventory must be specified")
+	}
+	inv, err := loadInventory(*app.config.inventoryPath)
+	if err != nil {
+		return err
+	}
+
+	if *app.config.serve.serverKeyFilePath == "" || *app.config.serve.serverCertFilePath == "" {
+		return errors.New("serve: server key file and server cert file must be specified")
+	}
+
+	if *app.config.serve.apiKey == "" && *app.config.serve.clientCAFilePath == "" {
+		return errors.New("serve: api key and/or client ca file must be specified")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	// mTLS
+	if *app.config.serve.clientCAFilePath != "" {
+		clientCAPem, err := os.ReadFile(*app.config.serve.clientCAFilePath)
+		if err != nil {
+			return fmt.Errorf("serve: failed to read client ca file (%w)", err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(clientCAPem) {
+			return errors.New("serve: failed to parse client ca file")
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if *app.config.serve.apiKey == "" {
+			// no api key means a client cert is the only way in
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	s := &apiServer{
+		app:          app,
+		inv:          inv,
+		jobs:         make(map[string]*apiJob),
+		printerLocks: make(map[string]*sync.Mutex),
+	}
+	for _, p := range inv.Printers {
+		s.printerLocks[p.Name] = &sync.Mutex{}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/printers/{name}/install", s.handleInstall)
+	mux.HandleFunc("GET /api/v1/printers/{name}/certs", s.handleListCerts)
+	mux.HandleFunc("GET /api/v1/jobs/{id}", s.handleJob)
+
+	srv := &http.Server{
+		Addr:              *app.config.serve.listenAddress,
+		Handler:           s.authenticate(mux),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	app.stdLogger.Printf("serve: listening on %s", srv.Addr)
+	err = runServer(ctx, srv, func() error {
+		return srv.ListenAndServeTLS(*app.config.serve.serverCertFilePath, *app.config.serve.serverKeyFilePath)
+	})
+
+	// runServer returns after in-flight requests are done, so no new jobs
+	// can start now
+	app.stdLogger.Println("serve: waiting for running jobs to finish")
+	s.running.Wait()
+
+	if err != nil {
+		return fmt.Errorf("serve: server failed (%w)", err)
+	}
+
+	return nil
+}
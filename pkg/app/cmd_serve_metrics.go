@@ -0,0 +1,320 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// serveMetricsCfg contains the config options for the serve-metrics subcommand
+type serveMetricsCfg struct {
+	listenAddress *string
+	interval      *time.Duration
+}
+
+// metricsTarget is a printer probed by the metrics exporter
+type metricsTarget struct {
+	name     string
+	hostname string
+	password string
+	useHttp  bool
+}
+
+// metricsProbe is the result of the most recent probe of a target
+type metricsProbe struct {
+	success     bool
+	probeTime   time.Time
+	serial      string
+	notBefore   time.Time
+	notAfter    time.Time
+	storedCerts int
+	// storedCertsKnown is false if the inventory could not be read (e.g. no
+	// password)
+	storedCertsKnown bool
+	lastInstall      time.Time
+	// loginLockedOut is true if the printer's login is locked out (the exporter
+	// doesn't try to log in again until the lockout ends)
+	loginLockedOut bool
+}
+
+// metricsExporter periodically probes printers and serves the results in
+// prometheus text format
+type metricsExporter struct {
+	app     *app
+	targets []metricsTarget
+
+	mu     sync.RWMutex
+	probes map[string]metricsProbe
+}
+
+// metricsTargets returns the printers to probe, from --hostname and
+// --inventory
+func (app *app) metricsTargets() ([]metricsTarget, error) {
+	targets := []metricsTarget{}
+
+	if app.config.hostname != nil && *app.config.hostname != "" {
+		targets = append(targets, metricsTarget{
+			name:     *app.config.hostname,
+			hostname: *app.config.hostname,
+			password: *app.config.password,
+			useHttp:  *app.config.http,
+		})
+	}
+
+	if app.config.inventoryPath != nil && *app.config.inventoryPath != "" {
+		inv, err := loadInventory(*app.config.inventoryPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range inv.Printers {
+			targets = append(targets, metricsTarget{
+				name:     p.Name,
+				hostname: p.Hostname,
+				password: p.Password,
+				useHttp:  p.Http,
+			})
+		}
+	}
+
+	if len(targets) <= 0 {
+		return nil, errors.New("serve-metrics: no printers specified (use --hostname or --inventory)")
+	}
+
+	return targets, nil
+}
+
+// probe checks one target and returns the result
+func (me *metricsExporter) probe(target metricsTarget) metricsProbe {
+	result := metricsProbe{
+		probeTime: time.Now(),
+	}
+
+	// last install comes from the state file (if configured)
+	if me.app.config.stateFilePath != nil && *me.app.config.stateFilePath != "" {
+		state, err := loadInstallState(*me.app.config.stateFilePath)
+		if err != nil {
+			me.app.errLogger.Printf("serve-metrics: %s", err)
+		} else {
+			result.lastInstall = state.LastInstall[target.hostname]
+		}
+	}
+
+	// current cert (no login needed)
+	printerCfg := printer.Config{
+		Hostname:  target.hostname,
+		Password:  target.password,
+		UseHttp:   target.useHttp,
+		UserAgent: userAgent(),
+	}
+
+	print, err := printer.NewPrinterNoLogin(printerCfg)
+	if err != nil {
+		me.app.errLogger.Printf("serve-metrics: probe of %s failed (%s)", target.name, err)
+		return result
+	}
+
+	cert, err := print.GetCurrentLeafCert()
+	if err != nil {
+		me.app.errLogger.Printf("serve-metrics: probe of %s failed (%s)", target.name, err)
+		return result
+	}
+
+	result.success = true
+	result.serial = fmt.Sprintf("%x", cert.SerialNumber.Bytes())
+	result.notBefore = cert.NotBefore
+	result.notAfter = cert.NotAfter
+
+	// cert inventory (only if credentials were given)
+	if target.password == "" {
+		return result
+	}
+
+	print, err = printer.NewPrinter(printerCfg)
+	if err != nil {
+		me.app.errLogger.Printf("serve-metrics: login to %s failed (%s)", target.name, err)
+		result.success = false
+		result.loginLockedOut = errors.Is(err, printer.ErrLockedOut)
+		return result
+	}
+	defer print.Close()
+
+	certIDs, err := print.GetCertIDs()
+	if err != nil {
+		me.app.errLogger.Printf("serve-metrics: cert list of %s failed (%s)", target.name, err)
+		result.success = false
+		return result
+	}
+
+	result.storedCerts = len(certIDs)
+	result.storedCertsKnown = true
+
+	return result
+}
+
+// probeAll probes all of the targets concurrently and saves the results
+func (me *metricsExporter) probeAll() {
+	var wg sync.WaitGroup
+	for _, target := range me.targets {
+		wg.Add(1)
+		go func(target metricsTarget) {
+			defer wg.Done()
+
+			result := me.probe(target)
+
+			me.mu.Lock()
+			me.probes[target.name] = result
+			me.mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+}
+
+// escapeLabelValue escapes a prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writeMetric writes one gauge (with HELP and TYPE) and its samples
+func writeMetric(b *strings.Builder, name, help string, samples []string) {
+	if len(samples) <= 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, sample := range samples {
+		fmt.Fprintf(b, "%s%s\n", name, sample)
+	}
+}
+
+// ServeHTTP writes the metrics from the most recent probes
+func (me *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	var probeSuccess, lastProbe, notBefore, notAfter, daysRemaining, storedCerts, lastInstall, loginLockedOut []string
+
+	// stable output order
+	names := make([]string, 0, len(me.probes))
+	for name := range me.probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hostnames := make(map[string]string)
+	for _, target := range me.targets {
+		hostnames[target.name] = target.hostname
+	}
+
+	for _, name := range names {
+		probe := me.probes[name]
+		labels := fmt.Sprintf(`{printer="%s",hostname="%s"}`, escapeLabelValue(name), escapeLabelValue(hostnames[name]))
+
+		success := 0
+		if probe.success {
+			success = 1
+		}
+		probeSuccess = append(probeSuccess, fmt.Sprintf("%s %d", labels, success))
+		lastProbe = append(lastProbe, fmt.Sprintf("%s %d", labels, probe.probeTime.Unix()))
+
+		if !probe.notAfter.IsZero() {
+			certLabels := fmt.Sprintf(`{printer="%s",hostname="%s",serial="%s"}`, escapeLabelValue(name), escapeLabelValue(hostnames[name]), probe.serial)
+			notBefore = append(notBefore, fmt.Sprintf("%s %d", certLabels, probe.notBefore.Unix()))
+			notAfter = append(notAfter, fmt.Sprintf("%s %d", certLabels, probe.notAfter.Unix()))
+			daysRemaining = append(daysRemaining, fmt.Sprintf("%s %d", certLabels, int(math.Floor(time.Until(probe.notAfter).Hours()/24))))
+		}
+
+		if probe.storedCertsKnown {
+			storedCerts = append(storedCerts, fmt.Sprintf("%s %d", labels, probe.storedCerts))
+		}
+
+		lockedOut := 0
+		if probe.loginLockedOut {
+			lockedOut = 1
+		}
+		loginLockedOut = append(loginLockedOut, fmt.Sprintf("%s %d", labels, lockedOut))
+
+		if !probe.lastInstall.IsZero() {
+			lastInstall = append(lastInstall, fmt.Sprintf("%s %d", labels, probe.lastInstall.Unix()))
+		}
+	}
+
+	b := &strings.Builder{}
+	writeMetric(b, "brother_cert_probe_success", "Whether the last probe of the printer succeeded.", probeSuccess)
+	writeMetric(b, "brother_cert_last_probe_timestamp_seconds", "Time of the last probe of the printer.", lastProbe)
+	writeMetric(b, "brother_cert_not_before_timestamp_seconds", "NotBefore of the printer's current certificate.", notBefore)
+	writeMetric(b, "brother_cert_not_after_timestamp_seconds", "NotAfter of the printer's current certificate.", notAfter)
+	writeMetric(b, "brother_cert_days_remaining", "Days until the printer's current certificate expires.", daysRemaining)
+	writeMetric(b, "brother_cert_stored_certificates", "Number of certificates stored on the printer.", storedCerts)
+	writeMetric(b, "brother_cert_last_install_timestamp_seconds", "Time of the last successful certificate install on the printer.", lastInstall)
+	writeMetric(b, "brother_cert_login_locked_out", "Whether the printer's login is locked out (too many failed logins).", loginLockedOut)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// cmdServeMetrics runs a prometheus exporter that periodically probes the
+// configured printers' certificates
+func (app *app) cmdServeMetrics(ctx context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("serve-metrics: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	if *app.config.serveMetrics.interval < time.Minute {
+		return errors.New("serve-metrics: interval must be at least 1m")
+	}
+
+	targets, err := app.metricsTargets()
+	if err != nil {
+		return err
+	}
+
+	me := &metricsExporter{
+		app:     app,
+		targets: targets,
+		probes:  make(map[string]metricsProbe),
+	}
+
+	// probe loop
+	go func() {
+		ticker := time.NewTicker(*app.config.serveMetrics.interval)
+		defer ticker.Stop()
+
+		for {
+			me.probeAll()
+			app.stdLogger.Printf("serve-metrics: probed %d printer(s)", len(me.targets))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", me)
+
+	srv := &http.Server{
+		Addr:              *app.config.serveMetrics.listenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	app.stdLogger.Printf("serve-metrics: listening on %s", srv.Addr)
+	err = runServer(ctx, srv, srv.ListenAndServe)
+	if err != nil {
+		return fmt.Errorf("serve-metrics: server failed (%w)", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// tlsPolicyCfg contains the TLS settings the printer is required to have
+// (used by the install flow and the tls-settings set subcommand)
+type tlsPolicyCfg struct {
+	serverMinVersion *string
+	clientMinVersion *string
+	cipherStrength   *string
+}
+
+// settings returns the policy as printer.TLSSettings (empty values are not
+// part of the policy)
+func (cfg tlsPolicyCfg) settings() (printer.TLSSettings, error) {
+	for _, version := range []string{*cfg.serverMinVersion, *cfg.clientMinVersion} {
+		switch version {
+		case "", "1.0", "1.1", "1.2", "1.3":
+			// valid
+		default:
+			return printer.TLSSettings{}, fmt.Errorf("tls version '%s' is invalid (must be 1.0, 1.1, 1.2, or 1.3)", version)
+		}
+	}
+
+	return printer.TLSSettings{
+		ServerMinVersion: *cfg.serverMinVersion,
+		ClientMinVersion: *cfg.clientMinVersion,
+		CipherStrength:   *cfg.cipherStrength,
+	}, nil
+}
+
+// configured returns true if any part of the policy was specified
+func (cfg tlsPolicyCfg) configured() bool {
+	return *cfg.serverMinVersion != "" || *cfg.clientMinVersion != "" || *cfg.cipherStrength != ""
+}
+
+// tlsSettingsString returns a human readable form of settings
+func tlsSettingsString(settings printer.TLSSettings) string {
+	s := fmt.Sprintf("server minimum version: TLS %s, client minimum version: TLS %s", settings.ServerMinVersion, settings.ClientMinVersion)
+	if settings.CipherStrength != "" {
+		s += fmt.Sprintf(", cipher strength: %s", settings.CipherStrength)
+	}
+
+	return s
+}
+
+// tlsPolicyChanges returns the settings that must be changed for current to
+// meet policy. A newer minimum version than required meets the policy.
+// needed is false if current already meets policy.
+func tlsPolicyChanges(current, policy printer.TLSSettings) (changes printer.TLSSettings, needed bool) {
+	// versions are all "1.x" so they compare as strings
+	if policy.ServerMinVersion != "" && current.ServerMinVersion < policy.ServerMinVersion {
+		changes.ServerMinVersion = policy.ServerMinVersion
+		needed = true
+	}
+	if policy.ClientMinVersion != "" && current.ClientMinVersion < policy.ClientMinVersion {
+		changes.ClientMinVersion = policy.ClientMinVersion
+		needed = true
+	}
+	if policy.CipherStrength != "" && !strings.EqualFold(current.CipherStrength, policy.CipherStrength) {
+		changes.CipherStrength = policy.CipherStrength
+		needed = true
+	}
+
+	return changes, needed
+}
+
+// tlsSettingsPrinter is a printer that can get and set its TLS settings
+type tlsSettingsPrinter interface {
+	GetTLSSettings() (printer.TLSSettings, error)
+	SetTLSSettings(settings printer.TLSSettings) error
+}
+
+// applyTLSPolicy changes the printer's TLS settings, if needed, so they meet
+// policy. progress is logged to logger.
+func applyTLSPolicy(logger *log.Logger, subcommand string, print tlsSettingsPrinter, policy printer.TLSSettings) error {
+	current, err := print.GetTLSSettings()
+	if err != nil {
+		return err
+	}
+
+	changes, needed := tlsPolicyChanges(current, policy)
+	if !needed {
+		logger.Printf("%s: tls settings already meet the policy (%s)", subcommand, tlsSettingsString(current))
+		return nil
+	}
+
+	logger.Printf("%s: updating tls settings to meet the policy...", subcommand)
+	err = print.SetTLSSettings(changes)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("%s: tls settings updated", subcommand)
+	return nil
+}
+
+// cmdTLSSettings logs the TLS settings of the printer (or all printers in the
+// inventory)
+func (app *app) cmdTLSSettings(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("tls-settings: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	names, printerCfgs, err := app.loginTargets("tls-settings")
+	if err != nil {
+		return err
+	}
+
+	// one failure shouldn't stop the rest
+	var errs []error
+	for i := range printerCfgs {
+		print, err := printer.NewPrinter(printerCfgs[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+
+		settings, err := print.GetTLSSettings()
+		_ = print.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+
+		app.stdLogger.Printf("tls-settings: %s: %s", names[i], tlsSettingsString(settings))
+	}
+
+	return errors.Join(errs...)
+}
+
+// setPrinterTLSSettings changes one printer's TLS settings to policy and logs
+// the resulting settings
+func (app *app) setPrinterTLSSettings(name string, printerCfg printer.Config, policy printer.TLSSettings) error {
+	print, err := printer.NewPrinter(printerCfg)
+	if err != nil {
+		return err
+	}
+	defer print.Close()
+	app.stdLogger.Printf("tls-settings set: connected to %s", name)
+
+	err = print.SetTLSSettings(policy)
+	if err != nil {
+		return err
+	}
+
+	settings, err := print.GetTLSSettings()
+	if err != nil {
+		return err
+	}
+	app.stdLogger.Printf("tls-settings set: %s: %s", name, tlsSettingsString(settings))
+
+	return nil
+}
+
+// cmdTLSSettingsSet changes the TLS settings of the printer (or all printers
+// in the inventory) to the values of the tls policy flags
+func (app *app) cmdTLSSettingsSet(_ context.Context, args []string) error {
+	// extra args == error
+	if len(args) != 0 {
+		return fmt.Errorf("tls-settings set: failed, %w (%d)", ErrExtraArgs, len(args))
+	}
+
+	if !app.config.tlsPolicy.configured() {
+		return errors.New("tls-settings set: at least one tls setting must be specified")
+	}
+
+	policy, err := app.config.tlsPolicy.settings()
+	if err != nil {
+		return fmt.Errorf("tls-settings set: %w", err)
+	}
+
+	names, printerCfgs, err := app.loginTargets("tls-settings set")
+	if err != nil {
+		return err
+	}
+
+	// one failure shouldn't stop the rest
+	var errs []error
+	for i := range printerCfgs {
+		err = app.setPrinterTLSSettings(names[i], printerCfgs[i], policy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
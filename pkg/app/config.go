@@ -2,8 +2,9 @@ package app
 
 import (
 	"errors"
-	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/peterbourgon/ff/v4"
 )
@@ -17,18 +18,37 @@ var (
 // keyCertPemCfg contains values common to subcommands that need to use key
 // and cert pem
 type keyCertPemCfg struct {
-	keyPemFilePath  *string
-	certPemFilePath *string
-	keyPem          *string
-	certPem         *string
+	pemFileCfg
+	certWarden certWardenCfg
+	vault      vaultCfg
+	urlSource  urlSourceCfg
 }
 
 // app's config options from user
 type config struct {
-	hostname *string
-	password *string
+	hostname       *string
+	password       *string
+	passwordSource passwordCfg
 	keyCertPemCfg
-	http *bool
+	http          *bool
+	inventoryPath *string
+	stateFilePath *string
+	hookMode      *string
+	tlsPolicy     tlsPolicyCfg
+	activate      activateCfg
+	httpsOnlyMode *string
+	httpsOnly     httpsOnlyCfg
+	profilePath   *string
+	sessionCache  *string
+	identity      identityCfg
+	ca            caCfg
+	check         checkCfg
+	serveMetrics  serveMetricsCfg
+	serve         serveCfg
+	passwordSet   passwordSetCfg
+	bootstrap     bootstrapCfg
+	audit         auditCfg
+	prune         pruneCfg
 }
 
 // getConfig returns the app's configuration from either command line args,
@@ -42,11 +62,43 @@ func (app *app) getConfig() error {
 
 	cfg.hostname = rootFlags.StringLong("hostname", "", "the hostname of the remote printer")
 	cfg.password = rootFlags.StringLong("password", "", "the password to login to the remote printer")
+	cfg.passwordSource.filePath = rootFlags.StringLong("password-file", "", "path and filename of a file containing the password to login to the remote printer")
+	cfg.passwordSource.stdin = rootFlags.BoolLong("password-stdin", "read the password to login to the remote printer from stdin")
+	cfg.passwordSource.command = rootFlags.StringLong("password-command", "", "command (credential helper) whose stdout is the password to login to the remote printer")
 	cfg.keyPemFilePath = rootFlags.StringLong("keyfile", "", "path and filename of the rsa-2048 key in pem format")
 	cfg.certPemFilePath = rootFlags.StringLong("certfile", "", "path and filename of the certificate in pem format")
 	cfg.keyPem = rootFlags.StringLong("keypem", "", "string of the rsa-2048 key in pem format")
 	cfg.certPem = rootFlags.StringLong("certpem", "", "string of the certificate in pem format")
+	cfg.certWarden.baseUrl = rootFlags.StringLong("certwarden-url", "", "base url of a cert warden server to download the key and cert from (e.g. https://certwarden.example.com)")
+	cfg.certWarden.keyName = rootFlags.StringLong("certwarden-keyname", "", "name of the private key on the cert warden server")
+	cfg.certWarden.keyApiKey = rootFlags.StringLong("certwarden-keyapikey", "", "api key of the private key on the cert warden server")
+	cfg.certWarden.certName = rootFlags.StringLong("certwarden-certname", "", "name of the certificate on the cert warden server")
+	cfg.certWarden.certApiKey = rootFlags.StringLong("certwarden-certapikey", "", "api key of the certificate on the cert warden server")
+	cfg.vault.addr = rootFlags.StringLong("vault-addr", "", "address of a vault server to issue the key and cert from (e.g. https://vault.example.com:8200)")
+	cfg.vault.token = rootFlags.StringLong("vault-token", "", "token to authenticate to the vault server")
+	cfg.vault.mount = rootFlags.StringLong("vault-pki-mount", "pki", "path the vault pki secrets engine is mounted at")
+	cfg.vault.role = rootFlags.StringLong("vault-role", "", "name of the vault pki role to issue the cert with (must issue rsa keys)")
+	cfg.vault.ttl = rootFlags.StringLong("vault-ttl", "", "requested ttl of the vault issued cert (e.g. 720h) (default: the role's ttl)")
+	cfg.vault.renewBefore = rootFlags.DurationLong("vault-renew-before", 30*24*time.Hour, "issue a new cert from vault only if the printer's current cert (issued by vault) expires within this long")
+	cfg.urlSource.keyUrl = rootFlags.StringLong("key-url", "", "https url to download the rsa-2048 key in pem format from")
+	cfg.urlSource.certUrl = rootFlags.StringLong("cert-url", "", "https url to download the certificate in pem format from")
+	cfg.urlSource.token = rootFlags.StringLong("url-token", "", "bearer token to send when downloading from --key-url and --cert-url")
 	cfg.http = rootFlags.BoolLong("http", "if this flag is set the connection to the printer will use http instead of https (INSECURE)")
+	cfg.stateFilePath = rootFlags.StringLong("state-file", "", "path and filename of a json file to record install times (used by serve-metrics) and uploaded certs (used to prune them) in")
+	cfg.hookMode = rootFlags.StringLong("hook-mode", "", "read the renewed key/cert and domain(s) from an acme client's deploy hook environment (certbot, acmesh, or lego)")
+	cfg.tlsPolicy.serverMinVersion = rootFlags.StringLong("tls-server-min-version", "", "required minimum tls version when the printer is the server, e.g. 1.2 (set during install if the printer's is lower)")
+	cfg.tlsPolicy.clientMinVersion = rootFlags.StringLong("tls-client-min-version", "", "required minimum tls version when the printer is the client, e.g. 1.2 (set during install if the printer's is lower)")
+	cfg.tlsPolicy.cipherStrength = rootFlags.StringLong("tls-cipher-strength", "", "required tls cipher strength, as labeled on the printer's tls settings page (not available on all models)")
+	cfg.activate.httpsWebUI = rootFlags.StringLong("https-webui", "keep", "when activating the new cert, enable (on) or disable (off) https for the web ui, or leave it as-is (keep)")
+	cfg.activate.httpsIPP = rootFlags.StringLong("https-ipp", "keep", "when activating the new cert, enable (on) or disable (off) ipp over tls, or leave it as-is (keep)")
+	cfg.activate.activateOtherProtocols = rootFlags.StringLong("activate-other-protocols", "", "when activating the new cert, also activate other protocols that have secure settings (on), or leave them as-is (off) (default: off, on for bootstrap)")
+	cfg.httpsOnlyMode = rootFlags.StringLong("https-only", "", "after install, confirm https works and then turn off plain http (disable) or redirect it to https (redirect)")
+	cfg.profilePath = rootFlags.StringLong("profile-file", "", "path and filename of a json printer profile (urls, field names, waits) to use instead of detecting the model (or, if it specifies models, for printers of those models)")
+	cfg.identity.serialNumber = rootFlags.StringLong("expect-serial", "", "refuse the printer (before sending any key) unless its serial number matches")
+	cfg.identity.macAddress = rootFlags.StringLong("expect-mac", "", "refuse the printer (before sending any key) unless its mac address matches")
+	cfg.identity.nodeName = rootFlags.StringLong("expect-node-name", "", "refuse the printer (before sending any key) unless its node name matches")
+	cfg.sessionCache = rootFlags.StringLong("session-cache", "", "path and filename of a json file to keep printer login sessions in, so consecutive runs don't need to log in again (contains session cookies)")
+	cfg.inventoryPath = rootFlags.StringLong("inventory", "", "path and filename of a json inventory of printers (for commands that support multiple printers)")
 
 	rootCmd := &ff.Command{
 		Name:      "brother-cert",
@@ -56,63 +108,285 @@ func (app *app) getConfig() error {
 		Exec:      app.cmdInstallCertAndReset,
 	}
 
-	// set cfg & parse
-	app.config = cfg
-	app.cmd = rootCmd
-	err := app.cmd.Parse(os.Args[1:], ff.WithEnvVarPrefix(environmentVarPrefix))
-	if err != nil {
-		return err
+	// brother-cert ca -- local root CA
+	caFlags := ff.NewFlagSet("ca").SetParent(rootFlags)
+	cfg.ca.keyFilePath = caFlags.StringLong("ca-keyfile", "brother-cert-ca.key", "path and filename of the local root ca's key in pem format")
+	cfg.ca.certFilePath = caFlags.StringLong("ca-certfile", "brother-cert-ca.pem", "path and filename of the local root ca's certificate in pem format")
+
+	caCmd := &ff.Command{
+		Name:      "ca",
+		Usage:     "brother-cert ca <SUBCOMMAND> [FLAGS]",
+		ShortHelp: "manage a local root ca that issues printer certificates",
+		Flags:     caFlags,
 	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, caCmd)
 
-	return nil
-}
+	// brother-cert ca init
+	caInitFlags := ff.NewFlagSet("init").SetParent(caFlags)
+	cfg.ca.commonName = caInitFlags.StringLong("ca-name", "brother-cert Local Root CA", "the common name of the new root ca")
+	cfg.ca.initDays = caInitFlags.IntLong("days", 3650, "the number of days the new root ca is valid")
 
-// GetPemBytes returns the key and cert pem bytes as specified in keyCertPemCfg
-// or an error if it cant get the bytes of both
-func (kcCfg *keyCertPemCfg) GetPemBytes(subcommand string) (keyPem, certPem []byte, err error) {
-	// key pem (from arg or file)
-	if kcCfg.keyPem != nil && *kcCfg.keyPem != "" {
-		// error if filename is also set
-		if kcCfg.keyPemFilePath != nil && *kcCfg.keyPemFilePath != "" {
-			return nil, nil, fmt.Errorf("%s: failed, both key pem and key file specified", subcommand)
-		}
+	caInitCmd := &ff.Command{
+		Name:      "init",
+		Usage:     "brother-cert ca init [--ca-keyfile ca.key --ca-certfile ca.pem] [FLAGS]",
+		ShortHelp: "create a new local root ca (existing files are never overwritten)",
+		Flags:     caInitFlags,
+		Exec:      app.cmdCAInit,
+	}
+	caCmd.Subcommands = append(caCmd.Subcommands, caInitCmd)
 
-		// use pem
-		keyPem = []byte(*kcCfg.keyPem)
-	} else {
-		// pem wasn't specified, try reading file
-		if kcCfg.keyPemFilePath == nil || *kcCfg.keyPemFilePath == "" {
-			return nil, nil, fmt.Errorf("%s: failed, neither key pem nor key file specified", subcommand)
-		}
+	// brother-cert ca issue
+	caIssueFlags := ff.NewFlagSet("issue").SetParent(caFlags)
+	cfg.ca.issueDays = caIssueFlags.IntLong("days", 365, "the number of days the new certificate is valid")
+	cfg.ca.sans = caIssueFlags.StringListLong("san", "additional dns name or ip address to include in the certificate (repeatable)")
+	cfg.ca.issueAndInstall = caIssueFlags.BoolLong("issue-and-install", "install the root ca and the newly issued key/cert on the printer")
+	cfg.ca.noCAUpload = caIssueFlags.BoolLong("no-ca-upload", "when installing, skip uploading the root ca (i.e. it is already installed)")
 
-		// read file to get pem
-		keyPem, err = os.ReadFile(*kcCfg.keyPemFilePath)
-		if err != nil {
-			return nil, nil, fmt.Errorf("%s: failed to read key file (%w)", subcommand, err)
-		}
+	caIssueCmd := &ff.Command{
+		Name:      "issue",
+		Usage:     "brother-cert ca issue --hostname printer.example.com [--keyfile key.pem --certfile cert.pem] [FLAGS]",
+		ShortHelp: "issue an rsa-2048 server certificate for the printer from the local root ca",
+		LongHelp: "The new key and certificate are saved to --keyfile and --certfile. If --issue-and-install is\n" +
+			"set, the root ca and the new key/cert are also installed on the printer (which requires --password).",
+		Flags: caIssueFlags,
+		Exec:  app.cmdCAIssue,
 	}
+	caCmd.Subcommands = append(caCmd.Subcommands, caIssueCmd)
 
-	// cert pem (repeat same process)
-	if kcCfg.certPem != nil && *kcCfg.certPem != "" {
-		// error if filename is also set
-		if kcCfg.certPemFilePath != nil && *kcCfg.certPemFilePath != "" {
-			return nil, nil, fmt.Errorf("%s: failed, both cert pem and cert file specified", subcommand)
-		}
+	// brother-cert check
+	checkFlags := ff.NewFlagSet("check").SetParent(rootFlags)
+	cfg.check.warningDays = checkFlags.IntLong("warning", 30, "return WARNING if the certificate expires in fewer than this many days")
+	cfg.check.criticalDays = checkFlags.IntLong("critical", 14, "return CRITICAL if the certificate expires in fewer than this many days")
 
-		// use pem
-		certPem = []byte(*kcCfg.certPem)
-	} else {
-		// pem wasn't specified, try reading file
-		if kcCfg.certPemFilePath == nil || *kcCfg.certPemFilePath == "" {
-			return nil, nil, fmt.Errorf("%s: failed, neither cert pem nor cert file specified", subcommand)
-		}
+	checkCmd := &ff.Command{
+		Name:      "check",
+		Usage:     "brother-cert check [--hostname printer.example.com] [--inventory printers.json] [FLAGS] [HOSTNAME...]",
+		ShortHelp: "check printer certificate expiration (Nagios/Icinga plugin output and exit codes)",
+		LongHelp: "Login is not required. Exit codes are 0 (OK), 1 (WARNING), 2 (CRITICAL), and 3 (UNKNOWN).\n" +
+			"When more than one printer is checked, the most severe state is returned.",
+		Flags: checkFlags,
+		Exec:  app.cmdCheck,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, checkCmd)
+
+	// brother-cert serve-metrics
+	serveMetricsFlags := ff.NewFlagSet("serve-metrics").SetParent(rootFlags)
+	cfg.serveMetrics.listenAddress = serveMetricsFlags.StringLong("listen", ":9753", "the address to serve metrics on")
+	cfg.serveMetrics.interval = serveMetricsFlags.DurationLong("interval", 15*time.Minute, "how often to probe the printers")
+
+	serveMetricsCmd := &ff.Command{
+		Name:      "serve-metrics",
+		Usage:     "brother-cert serve-metrics [--hostname printer.example.com] [--inventory printers.json] [FLAGS]",
+		ShortHelp: "run a prometheus exporter for printer certificate state",
+		LongHelp: "Metrics are served at /metrics. The stored certificate count is only collected for printers\n" +
+			"with a password. The last install time is only collected if --state-file is used by both the\n" +
+			"install and the exporter.",
+		Flags: serveMetricsFlags,
+		Exec:  app.cmdServeMetrics,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, serveMetricsCmd)
+
+	// brother-cert serve
+	serveFlags := ff.NewFlagSet("serve").SetParent(rootFlags)
+	cfg.serve.listenAddress = serveFlags.StringLong("listen", ":8443", "the address to serve the api on")
+	cfg.serve.serverKeyFilePath = serveFlags.StringLong("server-keyfile", "", "path and filename of the api server's https key in pem format")
+	cfg.serve.serverCertFilePath = serveFlags.StringLong("server-certfile", "", "path and filename of the api server's https certificate in pem format")
+	cfg.serve.apiKey = serveFlags.StringLong("api-key", "", "api key clients must send in the X-API-Key (or Authorization: Bearer) header")
+	cfg.serve.clientCAFilePath = serveFlags.StringLong("client-ca-file", "", "path and filename of ca certificate(s) in pem format used to verify client certificates (mTLS)")
+
+	serveCmd := &ff.Command{
+		Name:      "serve",
+		Usage:     "brother-cert serve --inventory printers.json --server-keyfile key.pem --server-certfile cert.pem --api-key secret [FLAGS]",
+		ShortHelp: "run an https api to install certificates on the printers in the inventory",
+		LongHelp: "Endpoints:\n" +
+			"  POST /api/v1/printers/{name}/install  start an install of {\"key_pem\": \"...\", \"cert_pem\": \"...\"}\n" +
+			"  GET  /api/v1/jobs/{id}                status, logs, and result of an install\n" +
+			"  GET  /api/v1/printers/{name}/certs    list the certificates stored on the printer\n" +
+			"Requests are authenticated with the api key and/or a client certificate (mTLS).",
+		Flags: serveFlags,
+		Exec:  app.cmdServe,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, serveCmd)
+
+	// brother-cert password
+	passwordFlags := ff.NewFlagSet("password").SetParent(rootFlags)
+
+	passwordCmd := &ff.Command{
+		Name:      "password",
+		Usage:     "brother-cert password <SUBCOMMAND> [FLAGS]",
+		ShortHelp: "manage the printer administrator password",
+		Flags:     passwordFlags,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, passwordCmd)
+
+	// brother-cert password set
+	passwordSetFlags := ff.NewFlagSet("set").SetParent(passwordFlags)
+	cfg.passwordSet.newPassword = passwordSetFlags.StringLong("new-password", "", "the new administrator password")
+	cfg.passwordSet.newPasswordFilePath = passwordSetFlags.StringLong("new-password-file", "", "path and filename of a file containing the new administrator password")
+	cfg.passwordSet.generate = passwordSetFlags.BoolLong("generate", "generate a random new password (for each printer)")
+	cfg.passwordSet.storeCommand = passwordSetFlags.StringLong("store-command", "", "command (credential helper) to save the new password, which is written to its stdin")
+
+	passwordSetCmd := &ff.Command{
+		Name:      "set",
+		Usage:     "brother-cert password set --hostname printer.example.com --password secret --new-password newsecret [FLAGS]",
+		ShortHelp: "change the administrator password of the printer (or all printers in --inventory)",
+		LongHelp: "After the change, the new password is confirmed by logging in again. If --store-command is set,\n" +
+			"it is run with the new password on stdin and BROTHER_CERT_STORE_PRINTER and\n" +
+			"BROTHER_CERT_STORE_HOSTNAME in its environment.",
+		Flags: passwordSetFlags,
+		Exec:  app.cmdPasswordSet,
+	}
+	passwordCmd.Subcommands = append(passwordCmd.Subcommands, passwordSetCmd)
+
+	// brother-cert tls-settings
+	tlsSettingsFlags := ff.NewFlagSet("tls-settings").SetParent(rootFlags)
+
+	tlsSettingsCmd := &ff.Command{
+		Name:      "tls-settings",
+		Usage:     "brother-cert tls-settings --hostname printer.example.com --password secret [FLAGS]",
+		ShortHelp: "show the tls settings of the printer (or all printers in --inventory)",
+		Flags:     tlsSettingsFlags,
+		Exec:      app.cmdTLSSettings,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, tlsSettingsCmd)
+
+	// brother-cert tls-settings set
+	tlsSettingsSetFlags := ff.NewFlagSet("set").SetParent(tlsSettingsFlags)
+
+	tlsSettingsSetCmd := &ff.Command{
+		Name:      "set",
+		Usage:     "brother-cert tls-settings set --hostname printer.example.com --password secret --tls-server-min-version 1.2 [FLAGS]",
+		ShortHelp: "change the tls settings of the printer (or all printers in --inventory)",
+		LongHelp: "The settings are changed to the values of --tls-server-min-version, --tls-client-min-version,\n" +
+			"and --tls-cipher-strength. Settings that are not specified are left unchanged.",
+		Flags: tlsSettingsSetFlags,
+		Exec:  app.cmdTLSSettingsSet,
+	}
+	tlsSettingsCmd.Subcommands = append(tlsSettingsCmd.Subcommands, tlsSettingsSetCmd)
 
-		// read file to get pem
-		certPem, err = os.ReadFile(*kcCfg.certPemFilePath)
-		if err != nil {
-			return nil, nil, fmt.Errorf("%s: failed to read cert file (%w)", subcommand, err)
+	// brother-cert https-only
+	httpsOnlyFlags := ff.NewFlagSet("https-only").SetParent(rootFlags)
+	cfg.httpsOnly.redirect = httpsOnlyFlags.BoolLong("redirect", "redirect plain http to https instead of disabling it (not available on all models)")
+
+	httpsOnlyCmd := &ff.Command{
+		Name:      "https-only",
+		Usage:     "brother-cert https-only --hostname printer.example.com --password secret [FLAGS]",
+		ShortHelp: "turn off plain http on the printer (or all printers in --inventory)",
+		LongHelp: "Login over https (with a trusted, valid certificate) is confirmed first. If it fails, http is\n" +
+			"left as-is so the printer's web ui can't be locked out.",
+		Flags: httpsOnlyFlags,
+		Exec:  app.cmdHttpsOnly,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, httpsOnlyCmd)
+
+	// brother-cert audit
+	auditFlags := ff.NewFlagSet("audit").SetParent(rootFlags)
+	cfg.audit.format = auditFlags.StringLong("format", "table", "output format (table or json)")
+	cfg.audit.defaultPassword = auditFlags.StringLong("default-password", "", "the printer's default administrator password (e.g. from the label on the printer), to check it is no longer in use")
+	cfg.audit.warningDays = auditFlags.IntLong("warning", 30, "fail the certificate expiry check if the certificate expires in fewer than this many days")
+
+	auditCmd := &ff.Command{
+		Name:      "audit",
+		Usage:     "brother-cert audit --hostname printer.example.com --password secret [--format json] [FLAGS]",
+		ShortHelp: "audit the security settings of the printer (or all printers in --inventory), read-only",
+		LongHelp: "Reports enabled services, tls minimum versions, default password use, certificate expiry, and\n" +
+			"whether https is enforced. Each printer is scored out of 100 (high, medium, and low severity\n" +
+			"failures deduct 25, 10, and 5 points).",
+		Flags: auditFlags,
+		Exec:  app.cmdAudit,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, auditCmd)
+
+	// brother-cert plan
+	planFlags := ff.NewFlagSet("plan").SetParent(rootFlags)
+
+	planCmd := &ff.Command{
+		Name:      "plan",
+		Usage:     "brother-cert plan --hostname printer.example.com --password secret [FLAGS] DESIRED_STATE_FILE",
+		ShortHelp: "show the changes needed to bring the printer (or all printers in --inventory) to the desired state",
+		Flags:     planFlags,
+		Exec:      app.cmdPlan,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, planCmd)
+
+	// brother-cert apply
+	applyFlags := ff.NewFlagSet("apply").SetParent(rootFlags)
+
+	applyCmd := &ff.Command{
+		Name:      "apply",
+		Usage:     "brother-cert apply --hostname printer.example.com --password secret [FLAGS] DESIRED_STATE_FILE",
+		ShortHelp: "bring the printer (or all printers in --inventory) to the desired state",
+		LongHelp: "Only the needed changes are made. Changes that require a restart (the cert and http server\n" +
+			"settings) are made with a single restart.",
+		Flags: applyFlags,
+		Exec:  app.cmdApply,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, applyCmd)
+
+	// brother-cert bootstrap
+	bootstrapFlags := ff.NewFlagSet("bootstrap").SetParent(rootFlags)
+	cfg.bootstrap.caCertFilePath = bootstrapFlags.StringLong("ca-certfile", "", "path and filename of the root ca certificate (of the new certificate) in pem format to upload to the printer")
+	cfg.bootstrap.tlsMinVersion = bootstrapFlags.StringLong("tls-min-version", "1.2", "minimum tls version to set for the printer's server and client, unless --tls-server-min-version or --tls-client-min-version is specified (empty to leave unchanged)")
+	cfg.bootstrap.disableHttp = bootstrapFlags.BoolLong("disable-http", "after https is verified, disable plain http access to the web ui and ipp")
+
+	bootstrapCmd := &ff.Command{
+		Name:      "bootstrap",
+		Usage:     "brother-cert bootstrap --hostname printer.example.com --password secret --keyfile key.pem --certfile cert.pem --ca-certfile ca.pem [FLAGS]",
+		ShortHelp: "perform the initial ssl setup of a factory-fresh printer (over http)",
+		LongHelp: "Uploads the root ca, sets the minimum tls version, installs the key/cert, selects it for https\n" +
+			"(activating other secure protocols), and reboots the printer. Then, https is verified to be\n" +
+			"serving the new cert and, if --disable-http is set, plain http is turned off.",
+		Flags: bootstrapFlags,
+		Exec:  app.cmdBootstrap,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, bootstrapCmd)
+
+	// brother-cert prune
+	pruneFlags := ff.NewFlagSet("prune").SetParent(rootFlags)
+	cfg.prune.keep = pruneFlags.IntLong("keep", 0, "the number of the newest old certs to keep (the active cert is always kept)")
+
+	pruneCmd := &ff.Command{
+		Name:      "prune",
+		Usage:     "brother-cert prune --hostname printer.example.com --password secret --state-file state.json [--keep N] [FLAGS]",
+		ShortHelp: "delete old certs this tool uploaded from the printer (or all printers in --inventory)",
+		LongHelp: "Only certs recorded in --state-file as uploaded by this tool are deleted, oldest expiry first.\n" +
+			"The active cert and the Preset are never deleted.",
+		Flags: pruneFlags,
+		Exec:  app.cmdPrune,
+	}
+	rootCmd.Subcommands = append(rootCmd.Subcommands, pruneCmd)
+
+	// set cfg & parse
+	app.config = cfg
+	app.cmd = rootCmd
+	err := app.cmd.Parse(os.Args[1:], ff.WithEnvVarPrefix(environmentVarPrefix))
+
+	// commands with machine readable output must not log anything else to stdout
+	switch app.cmd.GetSelected() {
+	case checkCmd:
+		app.stdLogger.SetOutput(io.Discard)
+	case auditCmd:
+		if *cfg.audit.format == auditFormatJSON {
+			app.stdLogger.SetOutput(io.Discard)
 		}
 	}
 
-	return keyPem, certPem, nil
+	if err != nil {
+		return err
+	}
+
+	// prompt for missing password if the command logs in to a single printer
+	selected := app.cmd.GetSelected()
+	needsPassword := selected == rootCmd || selected == passwordSetCmd || selected == bootstrapCmd ||
+		selected == tlsSettingsCmd || selected == tlsSettingsSetCmd || selected == httpsOnlyCmd ||
+		selected == auditCmd || selected == planCmd || selected == applyCmd || selected == pruneCmd ||
+		(selected == caIssueCmd && *cfg.ca.issueAndInstall)
+	prompt := needsPassword && *cfg.hostname != "" && *cfg.inventoryPath == ""
+
+	err = cfg.resolvePassword(prompt)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
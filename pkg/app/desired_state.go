@@ -0,0 +1,473 @@
+package app
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// desired plain http states
+const (
+	plainHttpEnabled  = "enabled"
+	plainHttpDisabled = "disabled"
+	plainHttpRedirect = "redirect"
+)
+
+// desiredState is the desired security state of a printer, loaded from a
+// json file. Anything that is not specified is not managed.
+type desiredState struct {
+	Cert       *desiredCert    `json:"cert"`
+	TrustedCAs []string        `json:"trusted_cas"`
+	TLS        *desiredTLS     `json:"tls"`
+	Protocols  map[string]bool `json:"protocols"`
+	Http       *desiredHttp    `json:"http"`
+}
+
+// desiredCert is the key/cert the printer should be using
+type desiredCert struct {
+	KeyFile  string `json:"keyfile"`
+	CertFile string `json:"certfile"`
+}
+
+// desiredTLS is the printer's desired TLS settings
+type desiredTLS struct {
+	ServerMinVersion string `json:"server_min_version"`
+	ClientMinVersion string `json:"client_min_version"`
+	CipherStrength   string `json:"cipher_strength"`
+}
+
+// desiredHttp is the printer's desired HTTP Server Settings
+type desiredHttp struct {
+	HttpsWebUI *bool  `json:"https_webui"`
+	HttpsIPP   *bool  `json:"https_ipp"`
+	PlainHttp  string `json:"plain_http"`
+}
+
+// loadDesiredState reads and validates the desired state file at path
+func loadDesiredState(path string) (*desiredState, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read desired state file (%w)", err)
+	}
+
+	state := &desiredState{}
+	dec := json.NewDecoder(bytes.NewReader(fileBytes))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse desired state file (%w)", err)
+	}
+
+	if state.Cert != nil && (state.Cert.KeyFile == "" || state.Cert.CertFile == "") {
+		return nil, errors.New("desired state: cert must have a keyfile and certfile")
+	}
+
+	if state.TLS != nil {
+		for _, version := range []string{state.TLS.ServerMinVersion, state.TLS.ClientMinVersion} {
+			switch version {
+			case "", "1.0", "1.1", "1.2", "1.3":
+				// valid
+			default:
+				return nil, fmt.Errorf("desired state: tls version '%s' is invalid (must be 1.0, 1.1, 1.2, or 1.3)", version)
+			}
+		}
+	}
+
+	if state.Http != nil {
+		switch state.Http.PlainHttp {
+		case "", plainHttpEnabled, plainHttpDisabled, plainHttpRedirect:
+			// valid
+		default:
+			return nil, fmt.Errorf("desired state: plain_http must be %s, %s, or %s", plainHttpEnabled, plainHttpDisabled, plainHttpRedirect)
+		}
+
+		httpsWebUIOff := state.Http.HttpsWebUI != nil && !*state.Http.HttpsWebUI
+		if httpsWebUIOff && (state.Http.PlainHttp == plainHttpDisabled || state.Http.PlainHttp == plainHttpRedirect) {
+			return nil, errors.New("desired state: https_webui can't be false when plain_http is disabled or redirect")
+		}
+	}
+
+	return state, nil
+}
+
+// desiredCA is a CA cert that the printer should trust
+type desiredCA struct {
+	cert    *x509.Certificate
+	certPem []byte
+}
+
+// resolvedState is a desiredState with its files loaded, ready to compare
+// with printers
+type resolvedState struct {
+	*desiredState
+	keyPem   []byte
+	certPem  []byte
+	leafCert *x509.Certificate
+	cas      []desiredCA
+	// issueCert is true if the cert is issued for each printer (by
+	// issueDesiredCert) instead of being the same for all printers
+	issueCert bool
+	// renewCert is true if a cert would be issued for the printer (set by
+	// planDesiredCert, which doesn't issue one)
+	renewCert bool
+}
+
+// resolveDesiredState loads the files referenced by state. If state has no
+// cert, the key/cert source flags are used (if any are configured), except
+// that a source that issues certs is only used for each printer by
+// issueDesiredCert.
+func (app *app) resolveDesiredState(state *desiredState) (*resolvedState, error) {
+	resolved := &resolvedState{desiredState: state}
+
+	var err error
+	if state.Cert != nil {
+		resolved.keyPem, err = os.ReadFile(state.Cert.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("desired state: failed to read keyfile (%w)", err)
+		}
+		resolved.certPem, err = os.ReadFile(state.Cert.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("desired state: failed to read certfile (%w)", err)
+		}
+	} else if app.config.keyCertPemCfg.issuesCerts() {
+		resolved.issueCert = true
+	} else if app.config.keyCertPemCfg.anyConfigured() {
+		resolved.keyPem, resolved.certPem, err = app.config.keyCertPemCfg.GetPemBytes("desired state", "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resolved.certPem != nil {
+		resolved.leafCert, err = parseLeafCert(resolved.certPem)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, caFile := range state.TrustedCAs {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("desired state: failed to read trusted ca file (%w)", err)
+		}
+
+		caCert, err := parseLeafCert(caPem)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved.cas = append(resolved.cas, desiredCA{cert: caCert, certPem: caPem})
+	}
+
+	return resolved, nil
+}
+
+// desiredCertDue returns true if state's cert is issued for each printer and
+// the printer's (printerCfg) current cert is due for renewal
+func (app *app) desiredCertDue(subcommand string, state *resolvedState, printerCfg printer.Config) (bool, error) {
+	if !state.issueCert {
+		return false, nil
+	}
+
+	return app.config.keyCertPemCfg.needsNewCert(subcommand, printerCfg.Hostname, currentLeafCert(printerCfg))
+}
+
+// issueDesiredCert returns state with a cert newly issued for the printer
+// (printerCfg) by the configured source, if state's cert is issued for each
+// printer and the printer's current cert is due for renewal. Otherwise state
+// is returned unchanged (and the printer's cert isn't managed).
+func (app *app) issueDesiredCert(subcommand string, state *resolvedState, printerCfg printer.Config) (*resolvedState, error) {
+	due, err := app.desiredCertDue(subcommand, state, printerCfg)
+	if err != nil || !due {
+		return state, err
+	}
+
+	issued := *state
+	issued.keyPem, issued.certPem, err = app.config.keyCertPemCfg.GetPemBytes(subcommand, printerCfg.Hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	issued.leafCert, err = parseLeafCert(issued.certPem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &issued, nil
+}
+
+// planDesiredCert is issueDesiredCert without issuing a cert: if one would be
+// issued, the returned state's renewCert is true instead
+func (app *app) planDesiredCert(subcommand string, state *resolvedState, printerCfg printer.Config) (*resolvedState, error) {
+	due, err := app.desiredCertDue(subcommand, state, printerCfg)
+	if err != nil || !due {
+		return state, err
+	}
+
+	planned := *state
+	planned.renewCert = true
+
+	return &planned, nil
+}
+
+// stateChange is one difference between a printer's current and desired state
+type stateChange struct {
+	setting string
+	current string
+	desired string
+	// restart is true if the change requires the printer to restart
+	restart bool
+}
+
+// String returns the human readable form of the change
+func (c stateChange) String() string {
+	s := fmt.Sprintf("~ %s: %s -> %s", c.setting, c.current, c.desired)
+	if c.restart {
+		s += " (restart)"
+	}
+
+	return s
+}
+
+// statePlan is the changes needed to bring a printer to its desired state
+type statePlan struct {
+	changes []stateChange
+
+	uploadCAs   []desiredCA
+	tls         *printer.TLSSettings
+	protocols   map[string]bool
+	installCert bool
+	http        *printer.HttpSettingsChange
+}
+
+// needsRestart returns true if any change requires a restart
+func (plan *statePlan) needsRestart() bool {
+	for _, c := range plan.changes {
+		if c.restart {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serialString returns the serial as a colon separated hex string
+func serialString(serial []byte) string {
+	hexBytes := []string{}
+	for _, b := range serial {
+		hexBytes = append(hexBytes, hex.EncodeToString([]byte{b}))
+	}
+
+	return strings.Join(hexBytes, ":")
+}
+
+// stateReader is a printer whose current state can be read
+type stateReader interface {
+	GetCurrentLeafCert() (*x509.Certificate, error)
+	ListCACerts() ([]printer.CACertInfo, error)
+	GetTLSSettings() (printer.TLSSettings, error)
+	GetProtocolSettings() (map[string]bool, error)
+	GetHttpSettings() (printer.HttpSettings, error)
+}
+
+// planState reads the printer's current state and returns the plan to bring
+// it to state
+func planState(print stateReader, state *resolvedState) (*statePlan, error) {
+	plan := &statePlan{}
+
+	// cert (a failed handshake is no current cert, e.g. https is off on a
+	// printer being bootstrapped)
+	if state.leafCert != nil || state.renewCert {
+		current := "none (https handshake failed)"
+		currCert, err := print.GetCurrentLeafCert()
+		if err == nil {
+			current = fmt.Sprintf("%s (serial %s)", currCert.Subject.CommonName, serialString(currCert.SerialNumber.Bytes()))
+		}
+
+		if state.renewCert {
+			plan.changes = append(plan.changes, stateChange{
+				setting: "cert",
+				current: current,
+				desired: "new cert issued for the printer",
+				restart: true,
+			})
+		} else if currCert == nil || !bytes.Equal(currCert.SerialNumber.Bytes(), state.leafCert.SerialNumber.Bytes()) {
+			plan.installCert = true
+			plan.changes = append(plan.changes, stateChange{
+				setting: "cert",
+				current: current,
+				desired: fmt.Sprintf("%s (serial %s)", state.leafCert.Subject.CommonName, serialString(state.leafCert.SerialNumber.Bytes())),
+				restart: true,
+			})
+		}
+	}
+
+	// trusted cas
+	if len(state.cas) > 0 {
+		currCAs, err := print.ListCACerts()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ca := range state.cas {
+			installed := false
+			for _, currCA := range currCAs {
+				if bytes.Equal(currCA.Serial, ca.cert.SerialNumber.Bytes()) {
+					installed = true
+					break
+				}
+			}
+
+			if !installed {
+				plan.uploadCAs = append(plan.uploadCAs, ca)
+				plan.changes = append(plan.changes, stateChange{
+					setting: fmt.Sprintf("trusted_cas[%s]", ca.cert.Subject.CommonName),
+					current: "not installed",
+					desired: fmt.Sprintf("installed (serial %s)", serialString(ca.cert.SerialNumber.Bytes())),
+				})
+			}
+		}
+	}
+
+	// tls
+	if state.TLS != nil {
+		currTLS, err := print.GetTLSSettings()
+		if err != nil {
+			return nil, err
+		}
+
+		tlsChanges := printer.TLSSettings{}
+		if state.TLS.ServerMinVersion != "" && state.TLS.ServerMinVersion != currTLS.ServerMinVersion {
+			tlsChanges.ServerMinVersion = state.TLS.ServerMinVersion
+			plan.changes = append(plan.changes, stateChange{setting: "tls.server_min_version", current: currTLS.ServerMinVersion, desired: state.TLS.ServerMinVersion})
+		}
+		if state.TLS.ClientMinVersion != "" && state.TLS.ClientMinVersion != currTLS.ClientMinVersion {
+			tlsChanges.ClientMinVersion = state.TLS.ClientMinVersion
+			plan.changes = append(plan.changes, stateChange{setting: "tls.client_min_version", current: currTLS.ClientMinVersion, desired: state.TLS.ClientMinVersion})
+		}
+		if state.TLS.CipherStrength != "" && !strings.EqualFold(state.TLS.CipherStrength, currTLS.CipherStrength) {
+			tlsChanges.CipherStrength = state.TLS.CipherStrength
+			plan.changes = append(plan.changes, stateChange{setting: "tls.cipher_strength", current: currTLS.CipherStrength, desired: state.TLS.CipherStrength})
+		}
+
+		if tlsChanges != (printer.TLSSettings{}) {
+			plan.tls = &tlsChanges
+		}
+	}
+
+	// protocols
+	if len(state.Protocols) > 0 {
+		currProtocols, err := print.GetProtocolSettings()
+		if err != nil {
+			return nil, err
+		}
+
+		// sorted so output is consistent
+		names := []string{}
+		for name := range state.Protocols {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			found := false
+			for label, enabled := range currProtocols {
+				if !strings.EqualFold(label, name) {
+					continue
+				}
+
+				found = true
+				if enabled != state.Protocols[name] {
+					if plan.protocols == nil {
+						plan.protocols = make(map[string]bool)
+					}
+					plan.protocols[label] = state.Protocols[name]
+					// the printer may need to restart to activate them
+					plan.changes = append(plan.changes, stateChange{
+						setting: fmt.Sprintf("protocols[%s]", label),
+						current: enabledString(enabled),
+						desired: enabledString(state.Protocols[name]),
+						restart: true,
+					})
+				}
+				break
+			}
+
+			if !found {
+				return nil, fmt.Errorf("desired state: protocol '%s' not found on the printer", name)
+			}
+		}
+	}
+
+	// http
+	if state.Http != nil {
+		currHttp, err := print.GetHttpSettings()
+		if err != nil {
+			return nil, err
+		}
+
+		httpChange := printer.HttpSettingsChange{}
+		changed := false
+
+		if state.Http.HttpsWebUI != nil && *state.Http.HttpsWebUI != currHttp.HttpsWebUI {
+			httpChange.HttpsWebUI = state.Http.HttpsWebUI
+			changed = true
+			plan.changes = append(plan.changes, stateChange{setting: "http.https_webui", current: enabledString(currHttp.HttpsWebUI), desired: enabledString(*state.Http.HttpsWebUI), restart: true})
+		}
+		if state.Http.HttpsIPP != nil && *state.Http.HttpsIPP != currHttp.HttpsIPP {
+			httpChange.HttpsIPP = state.Http.HttpsIPP
+			changed = true
+			plan.changes = append(plan.changes, stateChange{setting: "http.https_ipp", current: enabledString(currHttp.HttpsIPP), desired: enabledString(*state.Http.HttpsIPP), restart: true})
+		}
+
+		currPlainHttp := plainHttpDisabled
+		if currHttp.RedirectHttp {
+			currPlainHttp = plainHttpRedirect
+		} else if currHttp.PlainHttp {
+			currPlainHttp = plainHttpEnabled
+		}
+
+		if state.Http.PlainHttp != "" && state.Http.PlainHttp != currPlainHttp {
+			on, off := true, false
+			switch state.Http.PlainHttp {
+			case plainHttpEnabled:
+				httpChange.PlainHttp = &on
+				if currHttp.RedirectHttp {
+					httpChange.RedirectHttp = &off
+				}
+			case plainHttpDisabled:
+				httpChange.PlainHttp = &off
+			case plainHttpRedirect:
+				// redirect requires http to be listening
+				httpChange.PlainHttp = &on
+				httpChange.RedirectHttp = &on
+			}
+
+			changed = true
+			plan.changes = append(plan.changes, stateChange{setting: "http.plain_http", current: currPlainHttp, desired: state.Http.PlainHttp, restart: true})
+		}
+
+		if changed {
+			plan.http = &httpChange
+		}
+	}
+
+	return plan, nil
+}
+
+// enabledString returns "enabled" or "disabled"
+func enabledString(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+
+	return "disabled"
+}
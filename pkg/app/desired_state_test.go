@@ -0,0 +1,214 @@
+package app
+
+import (
+	"crypto/x509"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// fakeStateReader is a stateReader that returns fixed settings
+type fakeStateReader struct {
+	leafCert  *x509.Certificate
+	caCerts   []printer.CACertInfo
+	tls       printer.TLSSettings
+	protocols map[string]bool
+	http      printer.HttpSettings
+}
+
+func (f *fakeStateReader) GetCurrentLeafCert() (*x509.Certificate, error) {
+	if f.leafCert == nil {
+		return nil, errors.New("fake printer: https handshake failed")
+	}
+
+	return f.leafCert, nil
+}
+
+func (f *fakeStateReader) ListCACerts() ([]printer.CACertInfo, error) {
+	return f.caCerts, nil
+}
+
+func (f *fakeStateReader) GetTLSSettings() (printer.TLSSettings, error) {
+	return f.tls, nil
+}
+
+func (f *fakeStateReader) GetProtocolSettings() (map[string]bool, error) {
+	return f.protocols, nil
+}
+
+func (f *fakeStateReader) GetHttpSettings() (printer.HttpSettings, error) {
+	return f.http, nil
+}
+
+func TestPlanState(t *testing.T) {
+	ca := newTestCert(t, "Test Root CA", time.Now().Add(365*24*time.Hour), nil)
+	current := newTestCert(t, "printer.example.com", time.Now().Add(30*24*time.Hour), ca)
+	desired := newTestCert(t, "printer.example.com", time.Now().Add(90*24*time.Hour), ca)
+
+	on, off := true, false
+
+	// newPrinter returns a printer with the current cert, the ca installed,
+	// TLS 1.2, Telnet off, FTP on, and plain http enabled
+	newPrinter := func() *fakeStateReader {
+		return &fakeStateReader{
+			leafCert:  current.cert,
+			caCerts:   []printer.CACertInfo{{ID: "1", Serial: ca.cert.SerialNumber.Bytes()}},
+			tls:       printer.TLSSettings{ServerMinVersion: "1.2", ClientMinVersion: "1.2", CipherStrength: "Strong"},
+			protocols: map[string]bool{"Telnet": false, "FTP": true},
+			http:      printer.HttpSettings{HttpsWebUI: true, HttpsIPP: true, PlainHttp: true},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		print *fakeStateReader
+		state *resolvedState
+		// wantChanges are the settings of the changes, in order
+		wantChanges []string
+		wantRestart bool
+		check       func(t *testing.T, plan *statePlan)
+	}{
+		{
+			name:  "nothing to change",
+			print: newPrinter(),
+			state: &resolvedState{
+				desiredState: &desiredState{
+					TLS:       &desiredTLS{ServerMinVersion: "1.2", CipherStrength: "strong"},
+					Protocols: map[string]bool{"telnet": false},
+					Http:      &desiredHttp{HttpsWebUI: &on, PlainHttp: plainHttpEnabled},
+				},
+				leafCert: current.cert,
+				cas:      []desiredCA{{cert: ca.cert}},
+			},
+			wantChanges: nil,
+			wantRestart: false,
+			check: func(t *testing.T, plan *statePlan) {
+				if plan.installCert || plan.tls != nil || plan.protocols != nil || plan.http != nil || plan.uploadCAs != nil {
+					t.Errorf("got changes to apply (%+v), want none", plan)
+				}
+			},
+		},
+		{
+			name:  "tls and ca changes don't restart",
+			print: &fakeStateReader{tls: printer.TLSSettings{ServerMinVersion: "1.0", ClientMinVersion: "1.2"}},
+			state: &resolvedState{
+				desiredState: &desiredState{TLS: &desiredTLS{ServerMinVersion: "1.2", ClientMinVersion: "1.2"}},
+				cas:          []desiredCA{{cert: ca.cert}},
+			},
+			wantChanges: []string{"trusted_cas[Test Root CA]", "tls.server_min_version"},
+			wantRestart: false,
+			check: func(t *testing.T, plan *statePlan) {
+				if plan.tls == nil || *plan.tls != (printer.TLSSettings{ServerMinVersion: "1.2"}) {
+					t.Errorf("got tls %+v, want only the server version", plan.tls)
+				}
+				if len(plan.uploadCAs) != 1 {
+					t.Errorf("got %d cas to upload, want 1", len(plan.uploadCAs))
+				}
+			},
+		},
+		{
+			name:        "new cert",
+			print:       newPrinter(),
+			state:       &resolvedState{desiredState: &desiredState{}, leafCert: desired.cert},
+			wantChanges: []string{"cert"},
+			wantRestart: true,
+			check: func(t *testing.T, plan *statePlan) {
+				if !plan.installCert {
+					t.Error("cert isn't installed")
+				}
+			},
+		},
+		{
+			name:        "no current cert",
+			print:       &fakeStateReader{},
+			state:       &resolvedState{desiredState: &desiredState{}, leafCert: desired.cert},
+			wantChanges: []string{"cert"},
+			wantRestart: true,
+		},
+		{
+			name:        "cert to be issued",
+			print:       newPrinter(),
+			state:       &resolvedState{desiredState: &desiredState{}, issueCert: true, renewCert: true},
+			wantChanges: []string{"cert"},
+			wantRestart: true,
+			check: func(t *testing.T, plan *statePlan) {
+				// plan doesn't issue, so there is nothing to install
+				if plan.installCert {
+					t.Error("cert is installed, want only shown")
+				}
+			},
+		},
+		{
+			name:  "protocol changes restart with the printer's labels",
+			print: newPrinter(),
+			state: &resolvedState{
+				desiredState: &desiredState{Protocols: map[string]bool{"telnet": true, "ftp": false}},
+			},
+			wantChanges: []string{"protocols[FTP]", "protocols[Telnet]"},
+			wantRestart: true,
+			check: func(t *testing.T, plan *statePlan) {
+				if len(plan.protocols) != 2 || plan.protocols["Telnet"] != true || plan.protocols["FTP"] != false {
+					t.Errorf("got protocols %v, want Telnet on and FTP off", plan.protocols)
+				}
+			},
+		},
+		{
+			name:  "http changes restart",
+			print: newPrinter(),
+			state: &resolvedState{
+				desiredState: &desiredState{Http: &desiredHttp{HttpsIPP: &off, PlainHttp: plainHttpRedirect}},
+			},
+			wantChanges: []string{"http.https_ipp", "http.plain_http"},
+			wantRestart: true,
+			check: func(t *testing.T, plan *statePlan) {
+				if plan.http == nil {
+					t.Fatal("no http change")
+				}
+				// redirect requires http to be listening
+				if plan.http.HttpsIPP == nil || *plan.http.HttpsIPP ||
+					plan.http.PlainHttp == nil || !*plan.http.PlainHttp ||
+					plan.http.RedirectHttp == nil || !*plan.http.RedirectHttp {
+					t.Errorf("got http change %+v, want ipps off and http redirected", plan.http)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planState(tt.print, tt.state)
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			gotChanges := []string{}
+			for _, c := range plan.changes {
+				gotChanges = append(gotChanges, c.setting)
+			}
+			if !slices.Equal(gotChanges, tt.wantChanges) {
+				t.Fatalf("got changes %v, want %v", gotChanges, tt.wantChanges)
+			}
+
+			if plan.needsRestart() != tt.wantRestart {
+				t.Errorf("got restart %t, want %t", plan.needsRestart(), tt.wantRestart)
+			}
+
+			if tt.check != nil {
+				tt.check(t, plan)
+			}
+		})
+	}
+}
+
+func TestPlanStateUnknownProtocol(t *testing.T) {
+	print := &fakeStateReader{protocols: map[string]bool{"Telnet": false}}
+	state := &resolvedState{desiredState: &desiredState{Protocols: map[string]bool{"gopher": false}}}
+
+	_, err := planState(print, state)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// exit codes of failed commands (except check, which uses the monitoring
+// plugin states). They are documented in the README.
+const (
+	exitCodeFailed           = 1
+	exitCodeAuthFailed       = 10
+	exitCodeLockedOut        = 11
+	exitCodeUnreachable      = 12
+	exitCodeUnexpectedStatus = 13
+	exitCodeCSRFTokenMissing = 14
+	exitCodeStorageFull      = 15
+	exitCodeCertRejected     = 16
+	exitCodeUnsupportedKey   = 17
+	exitCodeRolledBack       = 18
+)
+
+// exitCodes map printer errors to exit codes, in order of precedence (e.g. a
+// rollback is reported over the error that caused it, and a full store over
+// the rejection it causes)
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{printer.ErrRolledBack, exitCodeRolledBack},
+	{printer.ErrLockedOut, exitCodeLockedOut},
+	{printer.ErrAuthFailed, exitCodeAuthFailed},
+	{printer.ErrUnreachable, exitCodeUnreachable},
+	{printer.ErrStorageFull, exitCodeStorageFull},
+	{printer.ErrCertRejected, exitCodeCertRejected},
+	{printer.ErrUnsupportedKey, exitCodeUnsupportedKey},
+	{printer.ErrCSRFTokenNotFound, exitCodeCSRFTokenMissing},
+	{printer.ErrUnexpectedStatus, exitCodeUnexpectedStatus},
+}
+
+// exitCodeOf returns the exit code for a command that failed with err
+func exitCodeOf(err error) int {
+	for _, ec := range exitCodes {
+		if errors.Is(err, ec.err) {
+			return ec.code
+		}
+	}
+
+	return exitCodeFailed
+}
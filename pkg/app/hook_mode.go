@@ -0,0 +1,168 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// supported acme client deploy hook modes
+const (
+	hookModeCertbot = "certbot"
+	hookModeAcmeSh  = "acmesh"
+	hookModeLego    = "lego"
+)
+
+// hookEnv is the renewed key/cert and domain(s) an acme client passes to its
+// deploy hook
+type hookEnv struct {
+	keyPath  string
+	certPath string
+	domains  []string
+}
+
+// requireEnv returns the values of the specified environment variables or an
+// error if any are missing
+func requireEnv(names ...string) ([]string, error) {
+	vals := []string{}
+	for _, name := range names {
+		val := os.Getenv(name)
+		if val == "" {
+			return nil, fmt.Errorf("hook: environment variable %s is not set", name)
+		}
+		vals = append(vals, val)
+	}
+
+	return vals, nil
+}
+
+// readHookEnv reads the environment variables the acme client sets for the
+// specified hook mode
+func readHookEnv(mode string) (hookEnv, error) {
+	switch mode {
+	case hookModeCertbot:
+		// e.g. RENEWED_LINEAGE=/etc/letsencrypt/live/example.com
+		//      RENEWED_DOMAINS="example.com www.example.com"
+		vals, err := requireEnv("RENEWED_LINEAGE", "RENEWED_DOMAINS")
+		if err != nil {
+			return hookEnv{}, err
+		}
+
+		return hookEnv{
+			keyPath:  filepath.Join(vals[0], "privkey.pem"),
+			certPath: filepath.Join(vals[0], "fullchain.pem"),
+			domains:  strings.Fields(vals[1]),
+		}, nil
+
+	case hookModeAcmeSh:
+		vals, err := requireEnv("CERT_KEY_PATH", "CERT_FULLCHAIN_PATH", "Le_Domain")
+		if err != nil {
+			return hookEnv{}, err
+		}
+
+		return hookEnv{
+			keyPath:  vals[0],
+			certPath: vals[1],
+			domains:  []string{vals[2]},
+		}, nil
+
+	case hookModeLego:
+		vals, err := requireEnv("LEGO_CERT_KEY_PATH", "LEGO_CERT_PATH", "LEGO_CERT_DOMAIN")
+		if err != nil {
+			return hookEnv{}, err
+		}
+
+		return hookEnv{
+			keyPath:  vals[0],
+			certPath: vals[1],
+			domains:  []string{vals[2]},
+		}, nil
+
+	default:
+		// no-op
+	}
+
+	return hookEnv{}, fmt.Errorf("hook: unsupported hook mode '%s' (must be %s, %s, or %s)", mode, hookModeCertbot, hookModeAcmeSh, hookModeLego)
+}
+
+// hookTargets returns the printers to install the renewed cert on. If an
+// inventory is specified, these are the printers whose hostname or domains
+// match one of the renewed domains (none isn't an error, since acme clients
+// run the hook for every renewal, including ones for other servers).
+// Otherwise, it is the --hostname printer.
+func (app *app) hookTargets(domains []string) ([]printer.Config, error) {
+	if app.config.inventoryPath == nil || *app.config.inventoryPath == "" {
+		printerCfg, err := app.printerConfig("hook")
+		if err != nil {
+			return nil, err
+		}
+
+		return []printer.Config{printerCfg}, nil
+	}
+
+	inv, err := loadInventory(*app.config.inventoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []printer.Config{}
+	for _, p := range inv.Printers {
+		if p.matchesDomain(domains) {
+			printerCfg := p.printerConfig()
+			printerCfg.SessionCachePath = app.sessionCachePath()
+			targets = append(targets, printerCfg)
+		}
+	}
+
+	if len(targets) <= 0 {
+		app.stdLogger.Printf("hook: no printer in inventory matches renewed domain(s) %s, nothing to do", strings.Join(domains, ", "))
+	}
+
+	return targets, nil
+}
+
+// cmdHookInstall installs the key and cert an acme client renewed on the
+// printer(s) mapped to the renewed domain(s)
+func (app *app) cmdHookInstall() error {
+	// hook mode provides the key and cert
+	if app.config.keyCertPemCfg.anyConfigured() {
+		return errors.New("hook: failed, another key/cert source can't be specified with hook mode")
+	}
+
+	env, err := readHookEnv(*app.config.hookMode)
+	if err != nil {
+		return err
+	}
+
+	keyPem, err := os.ReadFile(env.keyPath)
+	if err != nil {
+		return fmt.Errorf("hook: failed to read key file (%w)", err)
+	}
+
+	certPem, err := os.ReadFile(env.certPath)
+	if err != nil {
+		return fmt.Errorf("hook: failed to read cert file (%w)", err)
+	}
+
+	targets, err := app.hookTargets(env.domains)
+	if err != nil {
+		return err
+	}
+
+	// install on each printer (one failure shouldn't stop the rest)
+	var errs []error
+	for _, printerCfg := range targets {
+		app.stdLogger.Printf("hook: installing renewed cert on %s", printerCfg.Hostname)
+
+		err = app.installCertAndReset(app.stdLogger, printerCfg, keyPem, certPem)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", printerCfg.Hostname, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gregtwallace/brother-cert/pkg/printer"
+)
+
+// inventoryPrinter is one printer in the inventory file
+type inventoryPrinter struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+	Password string `json:"password"`
+	Http     bool   `json:"http"`
+	// Domains are additional certificate domains that map to this printer
+	// (e.g. for acme client hook mode)
+	Domains []string `json:"domains"`
+	// ProfileFile (optional) is a json printer profile to use instead of
+	// detecting the model
+	ProfileFile string `json:"profile_file"`
+	// SerialNumber, MACAddress, and NodeName (optional) are the printer's
+	// expected identity, checked before anything is sent to it
+	SerialNumber string `json:"serial_number"`
+	MACAddress   string `json:"mac_address"`
+	NodeName     string `json:"node_name"`
+
+	profile *printer.Profile
+}
+
+// printerConfig returns the printer.Config for the inventory printer
+func (ip inventoryPrinter) printerConfig() printer.Config {
+	return printer.Config{
+		Hostname:  ip.Hostname,
+		Password:  ip.Password,
+		UseHttp:   ip.Http,
+		UserAgent: userAgent(),
+		Profile:   ip.profile,
+		ExpectedIdentity: printer.Identity{
+			SerialNumber: ip.SerialNumber,
+			MACAddress:   ip.MACAddress,
+			NodeName:     ip.NodeName,
+		},
+	}
+}
+
+// matchesDomain returns true if any of domains is the printer's hostname or
+// one of its domains
+func (ip inventoryPrinter) matchesDomain(domains []string) bool {
+	for _, domain := range domains {
+		if strings.EqualFold(domain, ip.Hostname) {
+			return true
+		}
+
+		for _, ipDomain := range ip.Domains {
+			if strings.EqualFold(domain, ipDomain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// inventory is a list of printers for commands that operate on a fleet of
+// printers, loaded from a json file
+type inventory struct {
+	Printers []inventoryPrinter `json:"printers"`
+	// ProfileFiles (optional) are json printer profiles that are used for
+	// every printer of their models (each must specify models)
+	ProfileFiles []string `json:"profile_files"`
+}
+
+// loadProfileFile loads the json printer profile at path. A profile that
+// specifies models is registered, so that every printer of those models is
+// detected as it, and nil is returned. A profile without models is returned,
+// to be used instead of detecting the model.
+func loadProfileFile(path string) (*printer.Profile, error) {
+	profile, err := printer.LoadProfileFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profile.Models) > 0 {
+		printer.RegisterProfile(*profile)
+		return nil, nil
+	}
+
+	return profile, nil
+}
+
+// loadInventory reads and validates the inventory file at path
+func loadInventory(path string) (*inventory, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("inventory: failed to read file (%w)", err)
+	}
+
+	inv := &inventory{}
+	err = json.Unmarshal(fileBytes, inv)
+	if err != nil {
+		return nil, fmt.Errorf("inventory: failed to parse file (%w)", err)
+	}
+
+	// validate
+	names := make(map[string]struct{})
+	for i := range inv.Printers {
+		if inv.Printers[i].Hostname == "" {
+			return nil, fmt.Errorf("inventory: printer %d has no hostname", i)
+		}
+
+		// name defaults to hostname
+		if inv.Printers[i].Name == "" {
+			inv.Printers[i].Name = inv.Printers[i].Hostname
+		}
+
+		if _, exists := names[inv.Printers[i].Name]; exists {
+			return nil, fmt.Errorf("inventory: duplicate printer name '%s'", inv.Printers[i].Name)
+		}
+		names[inv.Printers[i].Name] = struct{}{}
+
+		if inv.Printers[i].ProfileFile != "" {
+			inv.Printers[i].profile, err = printer.LoadProfileFile(inv.Printers[i].ProfileFile)
+			if err != nil {
+				return nil, fmt.Errorf("inventory: printer '%s': %w", inv.Printers[i].Name, err)
+			}
+		}
+	}
+
+	if len(inv.Printers) <= 0 {
+		return nil, errors.New("inventory: no printers in file")
+	}
+
+	for _, path := range inv.ProfileFiles {
+		profile, err := printer.LoadProfileFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("inventory: %w", err)
+		}
+
+		if len(profile.Models) <= 0 {
+			return nil, fmt.Errorf("inventory: profile file '%s' must specify models (or be the profile_file of its printer(s))", path)
+		}
+		printer.RegisterProfile(*profile)
+	}
+
+	return inv, nil
+}
+
+// loginTargets returns the printers (and their names) a command that logs in
+// should operate on, from --inventory or --hostname
+func (app *app) loginTargets(subcommand string) (names []string, printerCfgs []printer.Config, err error) {
+	if *app.config.inventoryPath == "" {
+		printerCfg, err := app.printerConfig(subcommand)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []string{printerCfg.Hostname}, []printer.Config{printerCfg}, nil
+	}
+
+	inv, err := loadInventory(*app.config.inventoryPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, p := range inv.Printers {
+		printerCfg := p.printerConfig()
+		printerCfg.SessionCachePath = app.sessionCachePath()
+
+		names = append(names, p.Name)
+		printerCfgs = append(printerCfgs, printerCfg)
+	}
+
+	return names, printerCfgs, nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// passwordCfg contains the alternate ways to specify the printer password
+// (which avoid putting it in shell history, ps output, or the environment)
+type passwordCfg struct {
+	filePath *string
+	stdin    *bool
+	command  *string
+}
+
+// trimPassword removes the trailing line ending from a password read from a
+// file or command
+func trimPassword(password string) string {
+	return strings.TrimRight(password, "\r\n")
+}
+
+// shellCommand returns a command that runs cmdLine using the OS's shell
+func shellCommand(cmdLine string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", cmdLine)
+	}
+
+	return exec.Command("sh", "-c", cmdLine)
+}
+
+// runPasswordCommand runs the credential helper command and returns its
+// stdout as the password
+func runPasswordCommand(cmdLine string) (string, error) {
+	cmd := shellCommand(cmdLine)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("password command failed (%w)", err)
+	}
+
+	return trimPassword(stdout.String()), nil
+}
+
+// resolvePassword sets the password from the password file, stdin, or
+// command (if one was specified). If none was and prompt is true, the user
+// is prompted for it when a terminal is available.
+func (cfg *config) resolvePassword(prompt bool) error {
+	// only one source allowed
+	sources := 0
+	for _, specified := range []bool{*cfg.password != "", *cfg.passwordSource.filePath != "", *cfg.passwordSource.stdin, *cfg.passwordSource.command != ""} {
+		if specified {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("config: only one of password, password file, password stdin, or password command can be specified")
+	}
+
+	switch {
+	case *cfg.password != "":
+		// already set
+
+	case *cfg.passwordSource.filePath != "":
+		fileBytes, err := os.ReadFile(*cfg.passwordSource.filePath)
+		if err != nil {
+			return fmt.Errorf("config: failed to read password file (%w)", err)
+		}
+		*cfg.password = trimPassword(string(fileBytes))
+
+	case *cfg.passwordSource.stdin:
+		// first line only
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("config: failed to read password from stdin (%w)", err)
+		}
+		*cfg.password = trimPassword(line)
+
+	case *cfg.passwordSource.command != "":
+		password, err := runPasswordCommand(*cfg.passwordSource.command)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		*cfg.password = password
+
+	case prompt && term.IsTerminal(int(os.Stdin.Fd())):
+		fmt.Fprintf(os.Stderr, "Password for %s: ", *cfg.hostname)
+		passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("config: failed to read password (%w)", err)
+		}
+		*cfg.password = string(passwordBytes)
+
+	default:
+		// no password
+	}
+
+	return nil
+}
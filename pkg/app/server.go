@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// runServer runs srv using listenAndServe until ctx is done and then shuts
+// the server down gracefully. It returns once shutdown is done (in-flight
+// requests have finished or the shutdown timed out).
+func runServer(ctx context.Context, srv *http.Server, listenAndServe func() error) error {
+	// shutdown when ctx is done
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err := listenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	<-shutdownDone
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// installState records information about installs that isn't available from
+// the printers themselves (e.g. when a cert was last installed). It is saved
+// as a json file so it can be shared between runs (e.g. install and serve-metrics).
+type installState struct {
+	// LastInstall is keyed by printer hostname
+	LastInstall map[string]time.Time `json:"last_install"`
+	// ManagedCerts are the serials (hex) of the certs this tool uploaded,
+	// keyed by printer hostname. Only these certs are ever pruned.
+	ManagedCerts map[string][]string `json:"managed_certs,omitempty"`
+}
+
+// stateMu serializes updates of the state file (e.g. concurrent installs by
+// serve), so one update can't overwrite another
+var stateMu sync.Mutex
+
+// loadInstallState reads the state file at path. A missing file is not an
+// error and returns an empty state.
+func loadInstallState(path string) (*installState, error) {
+	state := &installState{
+		LastInstall:  make(map[string]time.Time),
+		ManagedCerts: make(map[string][]string),
+	}
+
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("state: failed to read file (%w)", err)
+	}
+
+	err = json.Unmarshal(fileBytes, state)
+	if err != nil {
+		return nil, fmt.Errorf("state: failed to parse file (%w)", err)
+	}
+
+	if state.LastInstall == nil {
+		state.LastInstall = make(map[string]time.Time)
+	}
+	if state.ManagedCerts == nil {
+		state.ManagedCerts = make(map[string][]string)
+	}
+
+	return state, nil
+}
+
+// updateInstallState loads the state file at path, changes it with update,
+// and saves it
+func updateInstallState(path string, update func(state *installState)) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	state, err := loadInstallState(path)
+	if err != nil {
+		return err
+	}
+
+	update(state)
+
+	stateBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("state: failed to encode (%w)", err)
+	}
+
+	// write to a temp file and rename so readers never see a partial file
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".brother-cert-state-*")
+	if err != nil {
+		return fmt.Errorf("state: failed to write file (%w)", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(stateBytes)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("state: failed to write file (%w)", err)
+	}
+
+	err = os.Rename(tmpFile.Name(), path)
+	if err != nil {
+		return fmt.Errorf("state: failed to write file (%w)", err)
+	}
+
+	return nil
+}
+
+// recordInstall updates the state file at path with the time of a successful
+// install on hostname
+func recordInstall(path, hostname string, installTime time.Time) error {
+	return updateInstallState(path, func(state *installState) {
+		state.LastInstall[hostname] = installTime.UTC()
+	})
+}
+
+// recordManagedCert updates the state file at path to record that the cert
+// with serial (hex) was uploaded to hostname by this tool
+func recordManagedCert(path, hostname, serial string) error {
+	return updateInstallState(path, func(state *installState) {
+		if !slices.Contains(state.ManagedCerts[hostname], serial) {
+			state.ManagedCerts[hostname] = append(state.ManagedCerts[hostname], serial)
+		}
+	})
+}
+
+// forgetManagedCert updates the state file at path to remove the record of
+// the cert with serial (hex) on hostname (e.g. after it was deleted)
+func forgetManagedCert(path, hostname, serial string) error {
+	return updateInstallState(path, func(state *installState) {
+		state.ManagedCerts[hostname] = slices.DeleteFunc(state.ManagedCerts[hostname], func(s string) bool {
+			return s == serial
+		})
+		if len(state.ManagedCerts[hostname]) <= 0 {
+			delete(state.ManagedCerts, hostname)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// printers have very limited storage for certificates and don't reliably
+// support anything other than rsa, so always use rsa-2048
+const keyBits = 2048
+
+var errNoHostnames = errors.New("ca: at least one hostname must be specified")
+
+// newKey generates a new rsa key and returns it along with its pem
+func newKey() (*rsa.PrivateKey, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ca: failed to generate key (%w)", err)
+	}
+
+	keyPem := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	return key, keyPem, nil
+}
+
+// newSerial returns a random 128 bit serial number
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("ca: failed to generate serial (%w)", err)
+	}
+
+	return serial, nil
+}
+
+// NewRootCA creates a new self-signed root CA with the specified common name
+// and validity. It returns the CA's key and certificate in pem format.
+func NewRootCA(commonName string, validity time.Duration) (keyPem, certPem []byte, err error) {
+	if commonName == "" {
+		return nil, nil, errors.New("ca: root common name must be specified")
+	}
+
+	key, keyPem, err := newKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// backdate slightly in case the printer's clock is a little behind
+	notBefore := time.Now().Add(-1 * time.Hour)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		// root only directly signs leafs
+		MaxPathLenZero: true,
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ca: failed to create root certificate (%w)", err)
+	}
+
+	certPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer})
+
+	return keyPem, certPem, nil
+}
+
+// parseCA returns the CA's key and cert from their pem
+func parseCA(caKeyPem, caCertPem []byte) (*rsa.PrivateKey, *x509.Certificate, error) {
+	// key
+	keyPemBlock, _ := pem.Decode(caKeyPem)
+	if keyPemBlock == nil {
+		return nil, nil, errors.New("ca: ca key pem block did not decode")
+	}
+
+	var key *rsa.PrivateKey
+	switch keyPemBlock.Type {
+	case "RSA PRIVATE KEY": // PKCS1
+		var err error
+		key, err = x509.ParsePKCS1PrivateKey(keyPemBlock.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ca: failed to parse ca key (%w)", err)
+		}
+
+	case "PRIVATE KEY": // PKCS8
+		pkcs8K, err := x509.ParsePKCS8PrivateKey(keyPemBlock.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ca: failed to parse ca key (%w)", err)
+		}
+
+		var ok bool
+		key, ok = pkcs8K.(*rsa.PrivateKey)
+		if !ok {
+			return nil, nil, errors.New("ca: only rsa ca keys are supported")
+		}
+
+	default:
+		return nil, nil, errors.New("ca: only rsa ca keys are supported")
+	}
+
+	// cert
+	certPemBlock, _ := pem.Decode(caCertPem)
+	if certPemBlock == nil {
+		return nil, nil, errors.New("ca: ca cert pem block did not decode")
+	}
+
+	cert, err := x509.ParseCertificate(certPemBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ca: failed to parse ca cert (%w)", err)
+	}
+
+	// key and cert must match
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil, errors.New("ca: ca key does not match ca cert")
+	}
+
+	if !cert.IsCA {
+		return nil, nil, errors.New("ca: ca cert is not a ca")
+	}
+
+	return key, cert, nil
+}
+
+// IssueServerCert signs a new rsa-2048 server certificate for the specified
+// hostnames using the specified CA. The first hostname is used as the Common
+// Name since some printers won't list certificates without one. It returns
+// the new key and certificate in pem format.
+func IssueServerCert(caKeyPem, caCertPem []byte, hostnames []string, validity time.Duration) (keyPem, certPem []byte, err error) {
+	if len(hostnames) <= 0 || hostnames[0] == "" {
+		return nil, nil, errNoHostnames
+	}
+
+	caKey, caCert, err := parseCA(caKeyPem, caCertPem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, keyPem, err := newKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// backdate slightly in case the printer's clock is a little behind
+	notBefore := time.Now().Add(-1 * time.Hour)
+	notAfter := notBefore.Add(validity)
+
+	// leaf can't outlive the CA
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: hostnames[0],
+		},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	// SANs
+	for _, hostname := range hostnames {
+		hostname = strings.TrimSpace(hostname)
+		if hostname == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(hostname); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, hostname)
+		}
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ca: failed to create server certificate (%w)", err)
+	}
+
+	// leaf only, the root is installed on the printer separately and an
+	// additional chain cert would only use up storage
+	certPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer})
+
+	return keyPem, certPem, nil
+}
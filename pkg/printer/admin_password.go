@@ -0,0 +1,131 @@
+package printer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	errAdminPasswordFieldsNotFound = errors.New("printer: set password: password fields not found in form")
+	errAdminPasswordRefused        = errors.New("printer: set password: printer refused the new password (showed the password form again)")
+)
+
+// adminPasswordInputs returns the password inputs of the password form in
+// bodyBytes, in order
+func (p *printer) adminPasswordInputs(bodyBytes []byte) []htmlInput {
+	return inputsOfType(parseBodyForForm(bodyBytes, p.profile.URLs.AdminPassword).inputs, "password")
+}
+
+// SetAdminPassword changes the printer's administrator (login) password from
+// the password used to login to newPassword. It returns an error if the
+// printer shows the password form again (e.g. the new password doesn't meet
+// its policy or the current password is wrong), since the password wasn't
+// changed.
+func (p *printer) SetAdminPassword(newPassword string) error {
+	if newPassword == "" {
+		return errors.New("printer: set password: new password is empty")
+	}
+
+	// GET password page to obtain CSRFToken and form fields
+	// get url & set path
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return err
+	}
+	u.Path = p.profile.URLs.AdminPassword
+
+	// make and do request
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// read body of response
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("get of password page", resp)
+	}
+
+	// find CSRFToken
+	csrfToken, err := parseBodyForCSRFToken(bodyBytes)
+	if err != nil {
+		return err
+	}
+
+	// form values
+	data := url.Values{}
+	inputs := parseBodyForForm(bodyBytes, p.profile.URLs.AdminPassword).inputs
+
+	// send back the form's hidden fields (e.g. pageid) as-is
+	for _, input := range inputsOfType(inputs, "hidden") {
+		if input["name"] != "" {
+			data.Set(input["name"], input["value"])
+		}
+	}
+	data.Set("CSRFToken", csrfToken)
+
+	// password fields are in order: current (not on all models), new, confirm
+	passwordInputs := p.adminPasswordInputs(bodyBytes)
+	switch len(passwordInputs) {
+	case 3:
+		data.Set(passwordInputs[0]["name"], p.password)
+		data.Set(passwordInputs[1]["name"], newPassword)
+		data.Set(passwordInputs[2]["name"], newPassword)
+
+	case 2:
+		data.Set(passwordInputs[0]["name"], newPassword)
+		data.Set(passwordInputs[1]["name"], newPassword)
+
+	default:
+		return errAdminPasswordFieldsNotFound
+	}
+
+	// make and do request
+	req, err = http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err = p.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// read body of response
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return withPageMessages(newStatusError("post of new password", resp), bodyBytes)
+	}
+
+	// the printer shows the password form again (with a message) if it
+	// refused the new password
+	if len(p.adminPasswordInputs(bodyBytes)) >= 2 {
+		return withPageMessages(errAdminPasswordRefused, bodyBytes)
+	}
+
+	p.password = newPassword
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestPrinter returns a printer (logged in with password) for the stub
+// web ui handler
+func newTestPrinter(t *testing.T, password string, handler http.Handler) *printer {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return &printer{
+		hostname:   "printer.example.com",
+		httpClient: srv.Client(),
+		baseUrl:    srv.URL,
+		password:   password,
+		profile:    defaultProfile,
+	}
+}
+
+func TestSetAdminPassword(t *testing.T) {
+	passwordPage := readTestPage(t, "password.html")
+	refusedPage := bytes.Replace(passwordPage, []byte(`<form method=post`),
+		[]byte(`<p class="errorMsg">The old password is incorrect.</p><form method=post`), 1)
+
+	tests := []struct {
+		name     string
+		response []byte
+		wantErr  error
+	}{
+		{
+			name:     "accepted",
+			response: readTestPage(t, "login.html"),
+		},
+		{
+			name:     "refused (form shown again)",
+			response: refusedPage,
+			wantErr:  errAdminPasswordRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted map[string]string
+			p := newTestPrinter(t, "old-password", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					_ = r.ParseForm()
+					posted = map[string]string{}
+					for name := range r.PostForm {
+						posted[name] = r.PostForm.Get(name)
+					}
+					_, _ = w.Write(tt.response)
+					return
+				}
+				_, _ = w.Write(passwordPage)
+			}))
+
+			err := p.SetAdminPassword("new-password")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error is %v, want %v", err, tt.wantErr)
+			}
+
+			want := map[string]string{
+				"CSRFToken": "UGFzc3dvcmQtVG9rZW4=",
+				"pageid":    "1",
+				"B10a":      "old-password",
+				"B10b":      "new-password",
+				"B10c":      "new-password",
+			}
+			for name, value := range want {
+				if posted[name] != value {
+					t.Errorf("posted %s is '%s', want '%s'", name, posted[name], value)
+				}
+			}
+
+			wantPassword := "new-password"
+			if tt.wantErr != nil {
+				wantPassword = "old-password"
+			}
+			if p.password != wantPassword {
+				t.Errorf("printer's password is '%s', want '%s'", p.password, wantPassword)
+			}
+		})
+	}
+}
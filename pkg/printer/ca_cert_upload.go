@@ -0,0 +1,201 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var errCACertFileFieldNotFound = errors.New("printer: ca upload: file field not found in import form")
+
+// getCACertIDs loads the CA certificate page and parses it to obtain the
+// IDs of the existing CA certificates
+func (p *printer) getCACertIDs() ([]string, error) {
+	entries, err := p.getCertList(p.profile.URLs.CACertList, p.profile.URLs.CACertView, "ca certificate list")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.id)
+	}
+
+	return ids, nil
+}
+
+// UploadCACert installs the specified pem certificate on the printer as a
+// trusted CA certificate. It returns the id value of the newly installed CA cert.
+func (p *printer) UploadCACert(certPem []byte) (string, error) {
+	// only the first cert is uploaded (the printer expects a single root)
+	cert, _, err := certPemToCerts(certPem)
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to parse cert (%w)", err)
+	}
+	if !cert.IsCA {
+		return "", errors.New("printer: ca upload: certificate is not a ca")
+	}
+
+	// GET current CA cert IDs
+	origCACertIDs, err := p.getCACertIDs()
+	if err != nil {
+		return "", err
+	}
+
+	// GET import page to obtain CSRFToken and the form's field names
+	// get url & set path
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return "", err
+	}
+	u.Path = p.profile.URLs.CACertImport
+
+	// make and do request
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// read body of response
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError("get of ca certificate import page", resp)
+	}
+
+	// find CSRFToken
+	csrfToken, err := parseBodyForCSRFToken(bodyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// the ca import page id and file field name are read from the form since
+	// they are not the same as the regular certificate import
+	inputs := parseBodyForForm(bodyBytes, p.profile.URLs.CACertImport).inputs
+
+	pageID, found := inputValue(inputs, "pageid")
+	if !found || pageID == "" {
+		return "", errors.New("printer: ca upload: pageid not found in import form")
+	}
+
+	fileInputs := inputsOfType(inputs, "file")
+	if len(fileInputs) != 1 || fileInputs[0]["name"] == "" {
+		return "", errCACertFileFieldNotFound
+	}
+
+	// make writer for multipart/form-data submission
+	var formDataBuffer bytes.Buffer
+	formWriter := multipart.NewWriter(&formDataBuffer)
+
+	// make form fields
+	err = formWriter.WriteField("pageid", pageID)
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to write form (%w)", err)
+	}
+
+	err = formWriter.WriteField("CSRFToken", csrfToken)
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to write form (%w)", err)
+	}
+
+	err = formWriter.WriteField("hidden_certificate_process_control", "1")
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to write form (%w)", err)
+	}
+
+	certW, err := formWriter.CreateFormFile(fileInputs[0]["name"], "ca.pem")
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to write form (%w)", err)
+	}
+
+	_, err = io.Copy(certW, bytes.NewReader(certPemFromCert(cert)))
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to write form (%w)", err)
+	}
+
+	err = formWriter.Close()
+	if err != nil {
+		return "", fmt.Errorf("printer: ca upload: failed to close form (%w)", err)
+	}
+
+	// make and do request
+	req, err = http.NewRequest(http.MethodPost, u.String(), &formDataBuffer)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", formWriter.FormDataContentType())
+
+	resp, err = p.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// read body of response (the printer's message, if it refused the cert)
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return "", withPageMessages(newStatusError("post of new ca certificate", resp), bodyBytes)
+	}
+
+	// give the device time to process the upload (same as the regular cert)
+	time.Sleep(p.profile.processingWait())
+
+	// get new CA cert ID list
+	newCACertIDs, err := p.getCACertIDs()
+	if err != nil {
+		return "", err
+	}
+
+	// find ID that is in new list but not in old (this is the new one)
+	newId := ""
+	countNew := 0
+	for i := range newCACertIDs {
+		found := false
+
+		// check if existed originally
+		for j := range origCACertIDs {
+			if newCACertIDs[i] == origCACertIDs[j] {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			newId = newCACertIDs[i]
+			countNew++
+		}
+	}
+
+	// none new means the printer rejected it
+	if countNew <= 0 {
+		return "", withPageMessages(ErrCertRejected, bodyBytes)
+	}
+
+	// more than one new means it can't be determined which was uploaded by
+	// this app
+	if countNew > 1 {
+		return "", errors.New("printer: ca upload: failed to deduce new ca cert's id")
+	}
+
+	return newId, nil
+}
@@ -2,7 +2,6 @@ package printer
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -10,10 +9,20 @@ import (
 	"time"
 )
 
-const urlCertDelete = "/net/security/certificate/delete.html"
-
 var errCertDeleteInvalidID = errors.New("printer: cant delete cert (invalid id)")
 
+// certDeleteFormValues returns the values to submit for the delete form in
+// bodyBytes, which are the page's own fields with csrfToken
+func (p *printer) certDeleteFormValues(bodyBytes []byte, csrfToken string) url.Values {
+	data := parseBodyForFormValues(bodyBytes, p.profile.URLs.CertDelete)
+	if data.Get("pageid") == "" {
+		data.Set("pageid", p.profile.PageIDs.CertDelete)
+	}
+	data.Set("CSRFToken", csrfToken)
+
+	return data
+}
+
 // DeleteCert deletes the certificate with the specified ID from the
 // printer
 func (p *printer) DeleteCert(id string) error {
@@ -44,7 +53,7 @@ func (p *printer) DeleteCert(id string) error {
 	if err != nil {
 		return err
 	}
-	u.Path = urlCertDelete
+	u.Path = p.profile.URLs.CertDelete
 
 	// make and do request
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
@@ -57,7 +66,7 @@ func (p *printer) DeleteCert(id string) error {
 	query.Set("idx", id)
 	req.URL.RawQuery = query.Encode()
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(req)
 	if err != nil {
 		return err
 	}
@@ -71,7 +80,7 @@ func (p *printer) DeleteCert(id string) error {
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("printer: get of delete page failed (status code %d)", resp.StatusCode)
+		return newStatusError("get of delete page", resp)
 	}
 
 	// find CSRFToken
@@ -81,12 +90,8 @@ func (p *printer) DeleteCert(id string) error {
 	}
 
 	// first delete form
-	// form values
-	data := url.Values{}
-	data.Set("pageid", "383")
-	data.Set("CSRFToken", csrfToken)
-	data.Set("B8ea", "")
-	data.Set("B8fc", "")
+	// form values (the page's own hidden fields)
+	data := p.certDeleteFormValues(bodyBytes, csrfToken)
 	data.Set("hidden_certificate_process_control", "1")
 	data.Set("hidden_certificate_idx", id)
 
@@ -95,7 +100,7 @@ func (p *printer) DeleteCert(id string) error {
 	if err != nil {
 		return err
 	}
-	u.Path = urlCertDelete
+	u.Path = p.profile.URLs.CertDelete
 
 	// make and do request
 	req, err = http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
@@ -105,7 +110,7 @@ func (p *printer) DeleteCert(id string) error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err = p.httpClient.Do(req)
+	resp, err = p.do(req)
 	if err != nil {
 		return err
 	}
@@ -119,22 +124,19 @@ func (p *printer) DeleteCert(id string) error {
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("printer: get failed (status code %d)", resp.StatusCode)
+		return withPageMessages(newStatusError("post of delete form", resp), bodyBytes)
 	}
 
-	// find CSRFToken
+	// find CSRFToken (the printer shows a message instead of the confirmation
+	// form if it won't delete the cert)
 	csrfToken, err = parseBodyForCSRFToken(bodyBytes)
 	if err != nil {
-		return err
+		return withPageMessages(err, bodyBytes)
 	}
 
 	// second delete (confirmation) form
-	// form values
-	data = url.Values{}
-	data.Set("pageid", "383")
-	data.Set("CSRFToken", csrfToken)
-	data.Set("B8ea", "")
-	data.Set("B8eb", "")
+	// form values (the confirmation page's own hidden fields)
+	data = p.certDeleteFormValues(bodyBytes, csrfToken)
 	data.Set("hidden_certificate_process_control", "2")
 	data.Set("hidden_certificate_idx", id)
 
@@ -143,7 +145,7 @@ func (p *printer) DeleteCert(id string) error {
 	if err != nil {
 		return err
 	}
-	u.Path = urlCertDelete
+	u.Path = p.profile.URLs.CertDelete
 
 	// make and do request
 	req, err = http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
@@ -153,19 +155,24 @@ func (p *printer) DeleteCert(id string) error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err = p.httpClient.Do(req)
+	resp, err = p.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	// read and discard entire body
-	_, _ = io.Copy(io.Discard, resp.Body)
+	// read body of response (the printer's message, if it refused). any
+	// status is accepted (e.g. a redirect), the id list check below is what
+	// confirms the delete.
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
 
 	// normally the webUI would show a waiting screen for ~7 seconds. insert
 	// a delay here to account for any processing the device might do
 	// before next steps
-	time.Sleep(10 * time.Second)
+	time.Sleep(p.profile.processingWait())
 
 	// check id list and ensure its gone
 	existingIDs, err = p.getCertIDs()
@@ -181,7 +188,7 @@ func (p *printer) DeleteCert(id string) error {
 		}
 	}
 	if idFound {
-		return errors.New("printer: failed to delete cert (still exists)")
+		return withPageMessages(errors.New("printer: failed to delete cert (still exists)"), bodyBytes)
 	}
 
 	return nil
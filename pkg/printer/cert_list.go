@@ -0,0 +1,106 @@
+package printer
+
+import "time"
+
+// CertInfo contains information about a certificate stored on the printer.
+// Values the printer doesn't show are empty.
+type CertInfo struct {
+	ID        string
+	Name      string
+	Serial    []byte
+	Issuer    string
+	Subject   string
+	NotBefore time.Time
+	NotAfter  time.Time
+	Active    bool
+}
+
+// ListCerts returns information about all of the certificates stored on the
+// printer
+func (p *printer) ListCerts() ([]CertInfo, error) {
+	entries, err := p.getCertList(p.profile.URLs.CertList, p.profile.URLs.CertView, "certificate list")
+	if err != nil {
+		return nil, err
+	}
+
+	// active cert id (failure isn't fatal, just means nothing is marked active)
+	activeID, _, err := p.GetCurrentCertID()
+	if err != nil {
+		activeID = ""
+	}
+
+	certs := []CertInfo{}
+	for _, entry := range entries {
+		view, err := p.getCertView(p.profile.URLs.CertView, entry.id)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, certInfoFromView(entry, view, entry.id == activeID))
+	}
+
+	return certs, nil
+}
+
+// certInfoFromView returns the CertInfo of the cert from its list entry and
+// view page
+func certInfoFromView(entry certListEntry, view certView, active bool) CertInfo {
+	// the list's name is preferred (it doesn't depend on the UI language)
+	name := entry.name
+	if name == "" {
+		name = view.name
+	}
+
+	return CertInfo{
+		ID:        entry.id,
+		Name:      name,
+		Serial:    view.serial,
+		Issuer:    view.issuer,
+		Subject:   view.subject,
+		NotBefore: view.notBefore,
+		NotAfter:  view.notAfter,
+		Active:    active,
+	}
+}
+
+// CACertInfo contains information about a CA certificate stored on the
+// printer. Values the printer doesn't show are empty.
+type CACertInfo struct {
+	ID        string
+	Name      string
+	Serial    []byte
+	Issuer    string
+	Subject   string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// ListCACerts returns information about all of the CA certificates stored on
+// the printer
+func (p *printer) ListCACerts() ([]CACertInfo, error) {
+	entries, err := p.getCertList(p.profile.URLs.CACertList, p.profile.URLs.CACertView, "ca certificate list")
+	if err != nil {
+		return nil, err
+	}
+
+	caCerts := []CACertInfo{}
+	for _, entry := range entries {
+		view, err := p.getCertView(p.profile.URLs.CACertView, entry.id)
+		if err != nil {
+			return nil, err
+		}
+
+		info := certInfoFromView(entry, view, false)
+		caCerts = append(caCerts, CACertInfo{
+			ID:        info.ID,
+			Name:      info.Name,
+			Serial:    info.Serial,
+			Issuer:    info.Issuer,
+			Subject:   info.Subject,
+			NotBefore: info.NotBefore,
+			NotAfter:  info.NotAfter,
+		})
+	}
+
+	return caCerts, nil
+}
@@ -8,11 +8,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sort"
 	"time"
 )
 
-const urlCertImport = "/net/security/certificate/import.html"
-
 // UploadNewCert converts the specified pem files into p12 format and installs them
 // on the printer. It returns the id value of the newly installed cert.
 func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
@@ -34,7 +33,7 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	u.Path = urlCertImport
+	u.Path = p.profile.URLs.CertImport
 
 	// make and do request
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
@@ -42,7 +41,7 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 		return "", err
 	}
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -56,7 +55,7 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("printer: get of certificate import page failed (status code %d)", resp.StatusCode)
+		return "", newStatusError("get of certificate import page", resp)
 	}
 
 	// find CSRFToken
@@ -65,37 +64,43 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 		return "", err
 	}
 
-	// make writer for multipart/form-data submission
-	var formDataBuffer bytes.Buffer
-	formWriter := multipart.NewWriter(&formDataBuffer)
-
-	// make form fields
-	err = formWriter.WriteField("pageid", "390")
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
+	// submit the page's own fields (hidden fields, empty password, etc.)
+	form := parseBodyForForm(bodyBytes, p.profile.URLs.CertImport)
+	data := form.values()
+	if data.Get("pageid") == "" {
+		data.Set("pageid", p.profile.PageIDs.CertImport)
 	}
+	data.Set("CSRFToken", csrfToken)
+	data.Set("hidden_certificate_process_control", "1")
+	data.Set("hidden_cert_import_password", "")
 
-	err = formWriter.WriteField("CSRFToken", csrfToken)
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
+	// the p12 goes in the page's file input
+	fileField, found := form.fileInputName()
+	if !found {
+		fileField = p.profile.Fields.CertImportFile
 	}
 
-	err = formWriter.WriteField("B8ea", "")
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
-	}
+	// make writer for multipart/form-data submission
+	var formDataBuffer bytes.Buffer
+	formWriter := multipart.NewWriter(&formDataBuffer)
 
-	err = formWriter.WriteField("B8f8", "")
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
+	// make form fields
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	err = formWriter.WriteField("hidden_certificate_process_control", "1")
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
+	for _, name := range names {
+		for _, value := range data[name] {
+			err = formWriter.WriteField(name, value)
+			if err != nil {
+				return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
+			}
+		}
 	}
 
-	p12W, err := formWriter.CreateFormFile("B820", "certkey.p12")
+	p12W, err := formWriter.CreateFormFile(fileField, "certkey.p12")
 	if err != nil {
 		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
 	}
@@ -105,16 +110,6 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
 	}
 
-	err = formWriter.WriteField("B821", "")
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
-	}
-
-	err = formWriter.WriteField("hidden_cert_import_password", "")
-	if err != nil {
-		return "", fmt.Errorf("printer: upload: failed to write form (%w)", err)
-	}
-
 	err = formWriter.Close()
 	if err != nil {
 		return "", fmt.Errorf("printer: upload: failed to close form (%w)", err)
@@ -125,7 +120,7 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	u.Path = urlCertImport
+	u.Path = p.profile.URLs.CertImport
 
 	// make and do request
 	req, err = http.NewRequest(http.MethodPost, u.String(), &formDataBuffer)
@@ -134,24 +129,27 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 	}
 	req.Header.Set("Content-Type", formWriter.FormDataContentType())
 
-	resp, err = p.httpClient.Do(req)
+	resp, err = p.do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	// read body of response
-	_, _ = io.Copy(io.Discard, resp.Body)
+	// read body of response (the printer's message, if it refused the cert)
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("printer: post of new certificate failed (status code %d)", resp.StatusCode)
+		return "", withPageMessages(newStatusError("post of new certificate", resp), bodyBytes)
 	}
 
 	// normally the webUI would show a waiting screen for ~7 seconds. insert
 	// a delay here to account for any processing the device might do
 	// before next steps
-	time.Sleep(10 * time.Second)
+	time.Sleep(p.profile.processingWait())
 
 	// get new cert ID list
 	newCertIDs, err := p.getCertIDs()
@@ -179,6 +177,11 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 		}
 	}
 
+	// none new means the printer rejected it
+	if countNew <= 0 {
+		return "", withPageMessages(ErrCertRejected, bodyBytes)
+	}
+
 	// if more than one new, can't determine which was uploaded by this app
 	if countNew > 1 {
 		return "", errors.New("printer: upload: failed to deduce new cert's id")
@@ -186,3 +189,26 @@ func (p *printer) UploadNewCert(keyPem, certPem []byte) (string, error) {
 
 	return newId, nil
 }
+
+// RollBackUpload deletes the uploaded cert with the specified ID after a
+// later step (e.g. activating it) failed with cause. It returns a
+// *RollbackError, or cause itself if the cert is (or may be) active, since
+// deleting the active cert isn't a rollback.
+func (p *printer) RollBackUpload(id string, cause error) error {
+	rollbackErr := &RollbackError{
+		Op:  fmt.Sprintf("install of cert (id: %s)", id),
+		Err: cause,
+	}
+
+	currentID, _, err := p.GetCurrentCertID()
+	if err != nil {
+		rollbackErr.RollbackErr = fmt.Errorf("failed to confirm the cert isn't active (%w)", err)
+		return rollbackErr
+	}
+	if currentID == id {
+		return cause
+	}
+
+	rollbackErr.RollbackErr = p.DeleteCert(id)
+	return rollbackErr
+}
@@ -11,8 +11,6 @@ import (
 
 // helper funcs to create p12 from pem
 
-var errUnsupportedKey = errors.New("printer: error: only rsa keys are supported")
-
 // keyPemToKey returns the private key from pemBytes
 func keyPemToKey(keyPem []byte) (key *rsa.PrivateKey, err error) {
 	// decode private key
@@ -62,7 +60,7 @@ func keyPemToKey(keyPem []byte) (key *rsa.PrivateKey, err error) {
 		// fallthrough
 	}
 
-	return nil, errUnsupportedKey
+	return nil, ErrUnsupportedKey
 }
 
 // certPemToCerts returns the certificate from cert pem bytes. if the pem
@@ -122,3 +120,8 @@ func makeModernPfx(keyPem, certPem []byte, password string) (pfxData []byte, err
 
 	return pfxData, nil
 }
+
+// certPemFromCert returns the pem encoding of the specified certificate
+func certPemFromCert(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
@@ -0,0 +1,351 @@
+package printer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The (CA) certificate list and view pages are parsed by structure and
+// value format instead of by their labels, since the labels are in the web
+// UI's language. Known labels are only used for values the structure can't
+// identify.
+
+var (
+	// e.g. `06:22:61:1a:32:3a:f8:ea:5b:be:3f:6c:53:a2:1e:d2:a4:c4`
+	regexSerialValue = regexp.MustCompile(`^:?[0-9A-Fa-f]{2}(?::[0-9A-Fa-f]{2})+:?$`)
+
+	// e.g. `CN=Example Root CA, O=Example`
+	regexDNValue = regexp.MustCompile(`(?:^|[\s,/])(?:CN|O|OU|C|L|ST)\s*=`)
+
+	// e.g. `2025/01/31 12:00:00` or `2025-1-31`
+	regexDateValue = regexp.MustCompile(`\d{4}[/-]\d{1,2}[/-]\d{1,2}(?:[ T]\d{1,2}:\d{2}(?::\d{2})?)?`)
+)
+
+// certViewField is a field of the certificate view page
+type certViewField int
+
+const (
+	certViewName certViewField = iota
+	certViewSerial
+	certViewIssuer
+	certViewSubject
+	certViewNotBefore
+	certViewNotAfter
+	certViewValidity
+)
+
+// certViewTerms are the known labels of the certificate view page, by UI
+// language (lower case, without a trailing colon)
+var certViewTerms = map[string]certViewField{
+	// English
+	"certificate name": certViewName,
+	"serial number":    certViewSerial,
+	"issuer":           certViewIssuer,
+	"subject":          certViewSubject,
+	"valid from":       certViewNotBefore,
+	"valid to":         certViewNotAfter,
+	"validity period":  certViewValidity,
+
+	// German
+	"zertifikatname":   certViewName,
+	"zertifikatsname":  certViewName,
+	"seriennummer":     certViewSerial,
+	"aussteller":       certViewIssuer,
+	"antragsteller":    certViewSubject,
+	"inhaber":          certViewSubject,
+	"gültig ab":        certViewNotBefore,
+	"gültig bis":       certViewNotAfter,
+	"gültigkeitsdauer": certViewValidity,
+
+	// French
+	"nom du certificat":   certViewName,
+	"numéro de série":     certViewSerial,
+	"émetteur":            certViewIssuer,
+	"sujet":               certViewSubject,
+	"objet":               certViewSubject,
+	"valide à partir du":  certViewNotBefore,
+	"valide jusqu'au":     certViewNotAfter,
+	"période de validité": certViewValidity,
+
+	// Spanish
+	"nombre del certificado": certViewName,
+	"número de serie":        certViewSerial,
+	"emisor":                 certViewIssuer,
+	"asunto":                 certViewSubject,
+	"período de validez":     certViewValidity,
+
+	// Italian
+	"nome certificato":    certViewName,
+	"numero di serie":     certViewSerial,
+	"emittente":           certViewIssuer,
+	"soggetto":            certViewSubject,
+	"periodo di validità": certViewValidity,
+
+	// Dutch
+	"certificaatnaam":    certViewName,
+	"serienummer":        certViewSerial,
+	"uitgever":           certViewIssuer,
+	"onderwerp":          certViewSubject,
+	"geldigheidsperiode": certViewValidity,
+}
+
+// certListEntry is one certificate on the (CA) certificate list page
+type certListEntry struct {
+	id string
+	// name is empty if the list doesn't show it
+	name string
+}
+
+// certView is the information on the (CA) certificate view page. Values that
+// couldn't be found are empty.
+type certView struct {
+	name      string
+	serial    []byte
+	issuer    string
+	subject   string
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// parseCertList returns the certificates on the (CA) certificate list page,
+// which are the links to viewPage (by file name). A cert's name is the first
+// other cell of the table row with its link.
+func parseCertList(bodyBytes []byte, viewPage string) []certListEntry {
+	entries := []certListEntry{}
+	seen := make(map[string]struct{})
+
+	for _, table := range parseBodyForTables(bodyBytes) {
+		for _, row := range table.rows {
+			ids := linkQueryValues(row.links, viewPage, "idx")
+			if len(ids) <= 0 {
+				continue
+			}
+			if _, exists := seen[ids[0]]; exists {
+				continue
+			}
+			seen[ids[0]] = struct{}{}
+
+			// first cell that isn't a link's text
+			name := ""
+			for _, cell := range row.cells {
+				if cell == "" || cell == row.links[0].text {
+					continue
+				}
+				name = cell
+				break
+			}
+
+			entries = append(entries, certListEntry{id: ids[0], name: name})
+		}
+	}
+
+	// links that aren't in a table have no name
+	for _, id := range parseBodyForLinkQueryValues(bodyBytes, viewPage, "idx") {
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		entries = append(entries, certListEntry{id: id})
+	}
+
+	return entries
+}
+
+// parseSerialHex converts a serial number hex string (e.g. `06:22:61`) into
+// hex data
+func parseSerialHex(serialHex string) ([]byte, error) {
+	// range over hex string and convert each value into a byte
+	byteChars := ""
+	serial := []byte{}
+
+	for i := range len(serialHex) {
+		// ensure each byte is exactly 2 characters
+		if serialHex[i] == '\x3A' {
+			// allow flexibility for invalid `:` at start and end of string
+			if (i != 0 && i != len(serialHex)-1) && len(byteChars) != 2 {
+				return nil, fmt.Errorf("serial format incorrect '%s'", serialHex)
+			}
+
+			// reset for next byte
+			byteChars = ""
+			continue
+		}
+
+		// append char to byteChars
+		byteChars += string(serialHex[i])
+
+		// not yet both chars
+		if len(byteChars) == 1 {
+			continue
+		}
+
+		// too many chars
+		if len(byteChars) > 2 {
+			return nil, fmt.Errorf("serial format incorrect '%s'", serialHex)
+		}
+
+		// convert the letter/number values into a byte
+		oneByte, err := hex.DecodeString(byteChars)
+		if err != nil {
+			return nil, fmt.Errorf("serial format incorrect '%s' (%s)", serialHex, err)
+		}
+
+		serial = append(serial, oneByte...)
+	}
+
+	return serial, nil
+}
+
+// parseViewDates returns the dates in value, in order. The printer's clock
+// time is treated as UTC.
+func parseViewDates(value string) []time.Time {
+	dates := []time.Time{}
+	for _, match := range regexDateValue.FindAllString(value, -1) {
+		match = strings.ReplaceAll(match, "-", "/")
+		match = strings.ReplaceAll(match, "T", " ")
+
+		for _, layout := range []string{"2006/1/2 15:04:05", "2006/1/2 15:04", "2006/1/2"} {
+			date, err := time.ParseInLocation(layout, match, time.UTC)
+			if err == nil {
+				dates = append(dates, date)
+				break
+			}
+		}
+	}
+
+	return dates
+}
+
+// parseCertViewPage returns the information on the (CA) certificate view
+// page. The serial, issuer, subject, and validity are identified by the
+// format of their values: the serial is colon separated hex, the issuer and
+// subject are distinguished names (issuer first), and the validity is the
+// first two dates. Known labels fill in anything else, such as the name.
+func parseCertViewPage(bodyBytes []byte) (certView, error) {
+	view := certView{}
+	defs := parseBodyForDefinitions(bodyBytes)
+
+	// structure
+	dns := []string{}
+	dates := []time.Time{}
+	for _, def := range defs {
+		switch {
+		case view.serial == nil && regexSerialValue.MatchString(def.description):
+			serial, err := parseSerialHex(def.description)
+			if err != nil {
+				return certView{}, err
+			}
+			view.serial = serial
+
+		case regexDNValue.MatchString(def.description):
+			dns = append(dns, def.description)
+
+		default:
+			dates = append(dates, parseViewDates(def.description)...)
+		}
+	}
+
+	if len(dns) > 0 {
+		view.issuer = dns[0]
+	}
+	if len(dns) > 1 {
+		view.subject = dns[1]
+	}
+	if len(dates) > 0 {
+		view.notBefore = dates[0]
+	}
+	if len(dates) > 1 {
+		view.notAfter = dates[1]
+	}
+
+	// known labels
+	for _, def := range defs {
+		field, known := certViewTerms[strings.TrimSuffix(strings.ToLower(def.term), ":")]
+		if !known {
+			continue
+		}
+
+		switch field {
+		case certViewName:
+			if view.name == "" {
+				view.name = def.description
+			}
+
+		case certViewSerial:
+			if view.serial == nil {
+				serial, err := parseSerialHex(def.description)
+				if err != nil {
+					return certView{}, err
+				}
+				view.serial = serial
+			}
+
+		case certViewIssuer:
+			if view.issuer == "" {
+				view.issuer = def.description
+			}
+
+		case certViewSubject:
+			if view.subject == "" {
+				view.subject = def.description
+			}
+
+		case certViewNotBefore, certViewNotAfter, certViewValidity:
+			fieldDates := parseViewDates(def.description)
+			if len(fieldDates) <= 0 {
+				continue
+			}
+
+			if field != certViewNotAfter && view.notBefore.IsZero() {
+				view.notBefore = fieldDates[0]
+			}
+			if field == certViewNotAfter && view.notAfter.IsZero() {
+				view.notAfter = fieldDates[0]
+			}
+			if field == certViewValidity && len(fieldDates) > 1 && view.notAfter.IsZero() {
+				view.notAfter = fieldDates[1]
+			}
+		}
+	}
+
+	return view, nil
+}
+
+// getCertList loads the (CA) certificate list page at listPath and returns
+// the certificates that link to the view page at viewPath. name describes
+// the list page for error messages.
+func (p *printer) getCertList(listPath, viewPath, name string) ([]certListEntry, error) {
+	bodyBytes, err := p.getPage(listPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// e.g. `<td><a href="view.html?idx=58">View</a></td>`
+	return parseCertList(bodyBytes, path.Base(viewPath)), nil
+}
+
+// getCertView loads the (CA) certificate view page at viewPath for the cert
+// id and parses it. The serial is required.
+func (p *printer) getCertView(viewPath, id string) (certView, error) {
+	bodyBytes, err := p.getPageQuery(viewPath, url.Values{"idx": {id}}, "certificate view")
+	if err != nil {
+		return certView{}, err
+	}
+
+	view, err := parseCertViewPage(bodyBytes)
+	if err != nil {
+		return certView{}, fmt.Errorf("printer: get cert serial for id '%s' from view page failed (%w)", id, err)
+	}
+
+	if view.serial == nil {
+		return certView{}, fmt.Errorf("printer: get cert serial for id '%s' from view page failed (unable to parse serial)", id)
+	}
+
+	return view, nil
+}
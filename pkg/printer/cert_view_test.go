@@ -0,0 +1,121 @@
+package printer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCertList(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want []certListEntry
+	}{
+		{
+			name: "list page (double, single, and unquoted links)",
+			body: readTestPage(t, "list.html"),
+			want: []certListEntry{
+				{id: "0", name: "Preset"},
+				{id: "3", name: "printer.example.com 2025"},
+				{id: "5", name: "printer example com (renewed)"},
+			},
+		},
+		{
+			name: "links outside of a table have no name",
+			body: []byte(`<p><a href='view.html?idx=2'>View</a> <a href=/net/security/certificate/view.html?idx=4>View</a></p>`),
+			want: []certListEntry{
+				{id: "2"},
+				{id: "4"},
+			},
+		},
+		{
+			name: "other pages' links aren't certs",
+			body: []byte(`<table><tr><td>Preset</td><td><a href="delete.html?idx=0">Delete</a></td></tr></table>`),
+			want: []certListEntry{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCertList(tt.body, "view.html")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCertViewPage(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want certView
+	}{
+		{
+			name: "view page",
+			body: readTestPage(t, "view.html"),
+			want: certView{
+				name:      "printer.example.com 2025",
+				serial:    []byte{0x06, 0x22, 0x61, 0x1a, 0x32, 0x3a, 0xf8, 0xea, 0x5b, 0xbe, 0x3f, 0x6c, 0x53, 0xa2, 0x1e, 0xd2, 0xa4, 0xc4},
+				issuer:    "CN=Example Root CA, O=Example Org",
+				subject:   "CN=printer.example.com",
+				notBefore: time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC),
+				notAfter:  time.Date(2025, 5, 1, 11, 59, 59, 0, time.UTC),
+			},
+		},
+		{
+			name: "translated labels (by value format)",
+			body: []byte(`<dl>` +
+				`<dt>Nom du certificat</dt><dd>Imprimante Bureau</dd>` +
+				`<dt>Numéro de série</dt><dd>:0a:1b:</dd>` +
+				`<dt>Émetteur</dt><dd>CN=Exemple CA</dd>` +
+				`<dt>Sujet</dt><dd>CN=imprimante.example.com</dd>` +
+				`<dt>Valide à partir du</dt><dd>2025-1-31</dd>` +
+				`<dt>Valide jusqu'au</dt><dd>2026-1-31</dd>` +
+				`</dl>`),
+			want: certView{
+				name:      "Imprimante Bureau",
+				serial:    []byte{0x0a, 0x1b},
+				issuer:    "CN=Exemple CA",
+				subject:   "CN=imprimante.example.com",
+				notBefore: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+				notAfter:  time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "unknown labels and values",
+			body: []byte(`<dl><dt>Something</dt><dd>else</dd></dl>`),
+			want: certView{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCertViewPage(tt.body)
+			if err != nil {
+				t.Fatalf("unexpected error (%s)", err)
+			}
+
+			if got.name != tt.want.name {
+				t.Errorf("name is '%s', want '%s'", got.name, tt.want.name)
+			}
+			if !bytes.Equal(got.serial, tt.want.serial) {
+				t.Errorf("serial is %x, want %x", got.serial, tt.want.serial)
+			}
+			if got.issuer != tt.want.issuer {
+				t.Errorf("issuer is '%s', want '%s'", got.issuer, tt.want.issuer)
+			}
+			if got.subject != tt.want.subject {
+				t.Errorf("subject is '%s', want '%s'", got.subject, tt.want.subject)
+			}
+			if !got.notBefore.Equal(tt.want.notBefore) {
+				t.Errorf("not before is %s, want %s", got.notBefore, tt.want.notBefore)
+			}
+			if !got.notAfter.Equal(tt.want.notAfter) {
+				t.Errorf("not after is %s, want %s", got.notAfter, tt.want.notAfter)
+			}
+		})
+	}
+}
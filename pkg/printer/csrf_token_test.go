@@ -0,0 +1,68 @@
+package printer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBodyForCSRFToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    string
+		wantErr error
+	}{
+		{
+			name: "double quotes",
+			body: []byte(`<form><input type="hidden" id="CSRFToken" name="CSRFToken" value="JRLbQ=="/></form>`),
+			want: "JRLbQ==",
+		},
+		{
+			name: "single quotes",
+			body: []byte(`<form><input type='hidden' id='CSRFToken' name='CSRFToken' value='JRLbQ=='></form>`),
+			want: "JRLbQ==",
+		},
+		{
+			name: "unquoted",
+			body: []byte(`<form><input type=hidden name=CSRFToken value=JRLbQ==></form>`),
+			want: "JRLbQ==",
+		},
+		{
+			name: "by id only",
+			body: []byte(`<input type="hidden" id="CSRFToken" name="token" value="abc">`),
+			want: "abc",
+		},
+		{
+			name:    "empty value",
+			body:    []byte(`<input type="hidden" name="CSRFToken" value="">`),
+			wantErr: ErrCSRFTokenNotFound,
+		},
+		{
+			name:    "missing",
+			body:    []byte(`<form><input type="hidden" name="pageid" value="390"></form>`),
+			wantErr: ErrCSRFTokenNotFound,
+		},
+		{
+			name: "import page",
+			body: readTestPage(t, "import.html"),
+			want: "SW1wb3J0LVRva2VuPT0=",
+		},
+		{
+			name: "delete page",
+			body: readTestPage(t, "delete.html"),
+			want: "RGVsZXRlLVRva2VuPT0=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBodyForCSRFToken(tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error is %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
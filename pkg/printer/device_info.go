@@ -0,0 +1,76 @@
+package printer
+
+import (
+	"regexp"
+	"time"
+)
+
+var (
+	// terms of the information page's definition list, e.g. `Model Name` and
+	// `Main Firmware Version`
+	regexModelTerm    = regexp.MustCompile(`(?i)model`)
+	regexFirmwareTerm = regexp.MustCompile(`(?i)firmware`)
+)
+
+// DeviceInfo is the printer's model and firmware, as detected after login
+type DeviceInfo struct {
+	Model    string
+	Firmware string
+}
+
+// parseDeviceInfo returns the DeviceInfo on the information page. The model
+// falls back to the page's title.
+func parseDeviceInfo(bodyBytes []byte) DeviceInfo {
+	info := DeviceInfo{}
+
+	for _, def := range parseBodyForDefinitions(bodyBytes) {
+		if info.Model == "" && regexModelTerm.MatchString(def.term) {
+			info.Model = def.description
+		}
+		if info.Firmware == "" && regexFirmwareTerm.MatchString(def.term) {
+			info.Firmware = def.description
+		}
+	}
+
+	if info.Model == "" {
+		// e.g. `<title>Brother MFC-L2710DW series</title>`
+		info.Model = parseBodyForTitle(bodyBytes)
+	}
+
+	return info
+}
+
+// detectProfile reads the printer's DeviceInfo (from the information page, or
+// the status page if that fails) and selects the matching built-in profile.
+// If detection fails, the current profile is kept.
+func (p *printer) detectProfile() {
+	for _, path := range []string{p.profile.URLs.Information, p.profile.URLs.Login} {
+		bodyBytes, err := p.getPage(path, "device info")
+		if err != nil {
+			continue
+		}
+
+		p.deviceInfo = parseDeviceInfo(bodyBytes)
+		if p.deviceInfo.Model != "" {
+			p.profile = ProfileForModel(p.deviceInfo.Model)
+			return
+		}
+	}
+}
+
+// DeviceInfo returns the printer's model and firmware (empty if they weren't
+// detected)
+func (p *printer) DeviceInfo() DeviceInfo {
+	return p.deviceInfo
+}
+
+// Profile returns the profile in use for the printer
+func (p *printer) Profile() Profile {
+	return p.profile
+}
+
+// RebootWait returns how long to wait for the printer to restart after a
+// settings change
+func (p *printer) RebootWait() time.Duration {
+	return p.profile.rebootWait()
+}
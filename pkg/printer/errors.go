@@ -0,0 +1,122 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors that callers can check for with errors.Is. Errors with more detail
+// are the struct types below, which match these sentinels with errors.Is and
+// can be inspected with errors.As.
+var (
+	// ErrAuthFailed means the printer refused to log in (ErrWrongPassword and
+	// ErrLockedOut also match it)
+	ErrAuthFailed = errors.New("printer: authentication failed")
+
+	// ErrCSRFTokenNotFound means a page didn't have the CSRF token its form
+	// needs (usually because the session isn't logged in)
+	ErrCSRFTokenNotFound = errors.New("printer: get: failed to find csrf token")
+
+	// ErrUnexpectedStatus means the printer responded with an unexpected http
+	// status code. The error is a *StatusError.
+	ErrUnexpectedStatus = errors.New("printer: unexpected http status")
+
+	// ErrStorageFull means the printer has no room to store another
+	// certificate
+	ErrStorageFull = errors.New("printer: certificate storage is full")
+
+	// ErrCertRejected means the printer didn't accept an uploaded certificate
+	ErrCertRejected = errors.New("printer: certificate was rejected by the printer")
+
+	// ErrUnsupportedKey means the key type isn't supported by the printer
+	ErrUnsupportedKey = errors.New("printer: error: only rsa keys are supported")
+
+	// ErrUnreachable means the printer couldn't be connected to. The error is
+	// an *UnreachableError.
+	ErrUnreachable = errors.New("printer: unreachable")
+
+	// ErrRolledBack means an operation failed part way and the changes it had
+	// made were undone. The error is a *RollbackError.
+	ErrRolledBack = errors.New("printer: rolled back")
+)
+
+// StatusError is the error of a request the printer responded to with an
+// unexpected http status code. It matches ErrUnexpectedStatus.
+type StatusError struct {
+	// Op describes the request (e.g. `get of certificate list page`)
+	Op         string
+	URL        string
+	StatusCode int
+}
+
+// newStatusError returns the StatusError of resp, for the request described by
+// op
+func newStatusError(op string, resp *http.Response) *StatusError {
+	e := &StatusError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.URL = resp.Request.URL.String()
+	}
+
+	return e
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("printer: %s failed (status code %d, url %s)", e.Op, e.StatusCode, e.URL)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrUnexpectedStatus
+}
+
+// UnreachableError is the error of a request that couldn't reach the printer
+// (e.g. connection refused, timeout, or tls handshake failure). It matches
+// ErrUnreachable.
+type UnreachableError struct {
+	Hostname string
+	Err      error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", ErrUnreachable, e.Hostname, e.Err)
+}
+
+func (e *UnreachableError) Is(target error) bool {
+	return target == ErrUnreachable
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// RollbackError is the error of an operation that failed after making
+// changes, which were then undone. Err is why the operation failed and
+// RollbackErr is why undoing failed (nil if the rollback succeeded). It
+// matches ErrRolledBack only if the rollback succeeded.
+type RollbackError struct {
+	// Op describes what was rolled back (e.g. `install of cert (id: 5)`)
+	Op          string
+	Err         error
+	RollbackErr error
+}
+
+func (e *RollbackError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("printer: %s failed (%s) and rolling back failed too (%s)", e.Op, e.Err, e.RollbackErr)
+	}
+	return fmt.Sprintf("printer: %s failed and was rolled back (%s)", e.Op, e.Err)
+}
+
+func (e *RollbackError) Is(target error) bool {
+	return target == ErrRolledBack && e.RollbackErr == nil
+}
+
+func (e *RollbackError) Unwrap() []error {
+	if e.RollbackErr != nil {
+		return []error{e.Err, e.RollbackErr}
+	}
+	return []error{e.Err}
+}
@@ -0,0 +1,654 @@
+package printer
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// helpers to parse the printer's web UI html pages. The body is parsed with
+// a real html parser, so quoting, attribute order, and whitespace don't
+// matter.
+
+// htmlInput contains the attributes of an html input element, keyed by
+// lower case attribute name
+type htmlInput map[string]string
+
+// htmlSelect is an html select element and its options
+type htmlSelect struct {
+	attrs   map[string]string
+	options []htmlOption
+}
+
+// htmlOption is one option of an html select element
+type htmlOption struct {
+	value    string
+	label    string
+	selected bool
+}
+
+// htmlLink is an html anchor element
+type htmlLink struct {
+	href string
+	text string
+}
+
+// htmlTable is an html table and its rows
+type htmlTable struct {
+	rows []htmlTableRow
+}
+
+// htmlTableRow is one row of an html table, as the text of each cell and the
+// links in the row
+type htmlTableRow struct {
+	cells []string
+	links []htmlLink
+}
+
+// htmlDefinition is one term and description of an html definition list
+type htmlDefinition struct {
+	term        string
+	description string
+}
+
+var regexMultiSpace = regexp.MustCompile(`\s+`)
+
+// parseHTML parses bodyBytes into a document. The html parser recovers from
+// malformed markup the same way a browser does, so it only fails if reading
+// fails, which can't happen with a byte slice.
+func parseHTML(bodyBytes []byte) *html.Node {
+	doc, err := html.Parse(bytes.NewReader(bodyBytes))
+	if err != nil {
+		return &html.Node{Type: html.DocumentNode}
+	}
+
+	return doc
+}
+
+// walkElements calls fn for each element under n, in document order. If fn
+// returns false, the element's children are skipped.
+func walkElements(n *html.Node, fn func(el *html.Node) bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && !fn(c) {
+			continue
+		}
+		walkElements(c, fn)
+	}
+}
+
+// findElements returns the elements under n with tag a, in document order
+func findElements(n *html.Node, a atom.Atom) []*html.Node {
+	els := []*html.Node{}
+	walkElements(n, func(el *html.Node) bool {
+		if el.DataAtom == a {
+			els = append(els, el)
+		}
+		return true
+	})
+
+	return els
+}
+
+// nodeAttrs returns the attributes of n, keyed by lower case name. The first
+// value wins (same as browsers).
+func nodeAttrs(n *html.Node) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range n.Attr {
+		name := strings.ToLower(attr.Key)
+		if _, exists := attrs[name]; exists {
+			continue
+		}
+		attrs[name] = attr.Val
+	}
+
+	return attrs
+}
+
+// nodeText returns the text content of n (entities unescaped and whitespace
+// collapsed)
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			// keep the words of adjacent elements apart
+			sb.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	return strings.TrimSpace(regexMultiSpace.ReplaceAllString(sb.String(), " "))
+}
+
+// parseBodyForInputs returns all of the input elements contained in the
+// html response, in document order
+func parseBodyForInputs(bodyBytes []byte) []htmlInput {
+	return inputsOfNode(parseHTML(bodyBytes))
+}
+
+// inputsOfNode returns all of the input elements under n, in document order
+func inputsOfNode(n *html.Node) []htmlInput {
+	inputs := []htmlInput{}
+	for _, el := range findElements(n, atom.Input) {
+		inputs = append(inputs, nodeAttrs(el))
+	}
+
+	return inputs
+}
+
+// inputsOfType returns the inputs that have the specified type
+func inputsOfType(inputs []htmlInput, inputType string) []htmlInput {
+	matches := []htmlInput{}
+	for _, input := range inputs {
+		if strings.EqualFold(input["type"], inputType) {
+			matches = append(matches, input)
+		}
+	}
+
+	return matches
+}
+
+// inputValue returns the value of the first input with the specified name
+func inputValue(inputs []htmlInput, name string) (value string, found bool) {
+	for _, input := range inputs {
+		if input["name"] == name {
+			return input["value"], true
+		}
+	}
+
+	return "", false
+}
+
+// parseBodyForSelects returns all of the select elements contained in the
+// html response, in document order
+func parseBodyForSelects(bodyBytes []byte) []htmlSelect {
+	return selectsOfNode(parseHTML(bodyBytes))
+}
+
+// selectsOfNode returns all of the select elements under n, in document order
+func selectsOfNode(n *html.Node) []htmlSelect {
+	selects := []htmlSelect{}
+	for _, el := range findElements(n, atom.Select) {
+		sel := htmlSelect{
+			attrs: nodeAttrs(el),
+		}
+
+		for _, optEl := range findElements(el, atom.Option) {
+			attrs := nodeAttrs(optEl)
+			label := nodeText(optEl)
+
+			// value defaults to the option's text
+			value, hasValue := attrs["value"]
+			if !hasValue {
+				value = label
+			}
+
+			_, selected := attrs["selected"]
+			sel.options = append(sel.options, htmlOption{
+				value:    value,
+				label:    label,
+				selected: selected,
+			})
+		}
+
+		selects = append(selects, sel)
+	}
+
+	return selects
+}
+
+// selectedValue returns the value of the selected option (or the first option
+// if none is selected, the same as a browser)
+func (sel htmlSelect) selectedValue() string {
+	for _, opt := range sel.options {
+		if opt.selected {
+			return opt.value
+		}
+	}
+
+	if len(sel.options) > 0 {
+		return sel.options[0].value
+	}
+
+	return ""
+}
+
+// parseBodyForLabels returns the text of the label elements contained in the
+// html response, keyed by the id of the element each label is for
+func parseBodyForLabels(bodyBytes []byte) map[string]string {
+	return labelsOfNode(parseHTML(bodyBytes))
+}
+
+// labelsOfNode returns the text of the label elements under n, keyed by the
+// id of the element each label is for
+func labelsOfNode(n *html.Node) map[string]string {
+	labels := make(map[string]string)
+
+	for _, el := range findElements(n, atom.Label) {
+		forID := nodeAttrs(el)["for"]
+
+		// a label without `for` labels the control inside of it
+		if forID == "" {
+			walkElements(el, func(c *html.Node) bool {
+				if c.DataAtom == atom.Input || c.DataAtom == atom.Select {
+					forID = nodeAttrs(c)["id"]
+				}
+				return forID == ""
+			})
+		}
+
+		if forID == "" {
+			continue
+		}
+
+		labels[forID] = nodeText(el)
+	}
+
+	return labels
+}
+
+// parseBodyForLinks returns all of the anchor elements with an href contained
+// in the html response, in document order
+func parseBodyForLinks(bodyBytes []byte) []htmlLink {
+	return linksOfNode(parseHTML(bodyBytes))
+}
+
+// linksOfNode returns all of the anchor elements with an href under n, in
+// document order
+func linksOfNode(n *html.Node) []htmlLink {
+	links := []htmlLink{}
+	for _, el := range findElements(n, atom.A) {
+		href, hasHref := nodeAttrs(el)["href"]
+		if !hasHref {
+			continue
+		}
+
+		links = append(links, htmlLink{
+			href: href,
+			text: nodeText(el),
+		})
+	}
+
+	return links
+}
+
+// parseBodyForLinkQueryValues returns the value of query key of each link to
+// page (by file name, e.g. `view.html`) contained in the html response, in
+// document order (e.g. the ids of `view.html?idx=58` links)
+func parseBodyForLinkQueryValues(bodyBytes []byte, page, key string) []string {
+	return linkQueryValues(parseBodyForLinks(bodyBytes), page, key)
+}
+
+// linkQueryValues returns the value of query key of each of links that is to
+// page (by file name, e.g. `view.html`), in order
+func linkQueryValues(links []htmlLink, page, key string) []string {
+	values := []string{}
+	for _, link := range links {
+		u, err := url.Parse(link.href)
+		if err != nil || path.Base(u.Path) != page {
+			continue
+		}
+
+		value := u.Query().Get(key)
+		if value == "" {
+			continue
+		}
+
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// parseBodyForTables returns the tables contained in the html response, in
+// document order. Nested tables are part of the text of the outer table's
+// cell.
+func parseBodyForTables(bodyBytes []byte) []htmlTable {
+	tables := []htmlTable{}
+	for _, tableEl := range findElements(parseHTML(bodyBytes), atom.Table) {
+		table := htmlTable{}
+
+		walkElements(tableEl, func(el *html.Node) bool {
+			switch el.DataAtom {
+			case atom.Table:
+				// nested table
+				return false
+
+			case atom.Tr:
+				row := htmlTableRow{
+					links: linksOfNode(el),
+				}
+				for c := el.FirstChild; c != nil; c = c.NextSibling {
+					if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+						row.cells = append(row.cells, nodeText(c))
+					}
+				}
+				table.rows = append(table.rows, row)
+				return false
+			}
+
+			return true
+		})
+
+		tables = append(tables, table)
+	}
+
+	return tables
+}
+
+// parseBodyForDefinitions returns the terms and descriptions of the
+// definition lists contained in the html response, in document order
+func parseBodyForDefinitions(bodyBytes []byte) []htmlDefinition {
+	defs := []htmlDefinition{}
+
+	for _, dlEl := range findElements(parseHTML(bodyBytes), atom.Dl) {
+		// each dd describes the dt before it
+		term := ""
+		for c := dlEl.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Dt:
+				term = nodeText(c)
+			case atom.Dd:
+				defs = append(defs, htmlDefinition{
+					term:        term,
+					description: nodeText(c),
+				})
+			}
+		}
+	}
+
+	return defs
+}
+
+// parseBodyForTitle returns the text of the html response's title element
+func parseBodyForTitle(bodyBytes []byte) string {
+	titles := findElements(parseHTML(bodyBytes), atom.Title)
+	if len(titles) <= 0 {
+		return ""
+	}
+
+	return nodeText(titles[0])
+}
+
+// inlineAtoms are the inline elements, whose text is part of the text of
+// the enclosing element
+var inlineAtoms = map[atom.Atom]struct{}{
+	atom.A: {}, atom.B: {}, atom.Em: {}, atom.Font: {}, atom.I: {}, atom.Small: {},
+	atom.Span: {}, atom.Strong: {}, atom.U: {}, atom.Br: {}, atom.Label: {},
+}
+
+// parseBodyForTextMatching returns the text of each element whose text
+// matches regex (e.g. a message on the page), in document order. The text is
+// that of the enclosing block (e.g. the whole paragraph, not just the bold
+// word that matched). Scripts, styles, the title, links (e.g. the menu),
+// select options, labels, and buttons aren't message text so they are
+// skipped.
+func parseBodyForTextMatching(bodyBytes []byte, regex *regexp.Regexp) []string {
+	texts := []string{}
+	seen := make(map[*html.Node]struct{})
+
+	walkElements(parseHTML(bodyBytes), func(el *html.Node) bool {
+		switch el.DataAtom {
+		case atom.Script, atom.Style, atom.Title, atom.Noscript, atom.A, atom.Nav, atom.Option, atom.Label, atom.Button:
+			return false
+		}
+
+		// only the element's own text nodes (children are visited separately)
+		matched := false
+		for c := el.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode && regex.MatchString(c.Data) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return true
+		}
+
+		// enclosing block (short of the whole body)
+		block := el
+		for block.Parent != nil && block.Parent.Type == html.ElementNode && block.Parent.DataAtom != atom.Body {
+			if _, inline := inlineAtoms[block.DataAtom]; !inline {
+				break
+			}
+			block = block.Parent
+		}
+
+		if _, exists := seen[block]; !exists {
+			seen[block] = struct{}{}
+			texts = append(texts, nodeText(block))
+		}
+
+		return true
+	})
+
+	return texts
+}
+
+// htmlForm is the model of one form on a web UI page
+type htmlForm struct {
+	inputs  []htmlInput
+	selects []htmlSelect
+	// labels are keyed by the id of the element each label is for (from the
+	// whole page, since a label may be outside of the form)
+	labels map[string]string
+}
+
+// parseBodyForForm returns the model of the form contained in the html
+// response that is submitted to page (by path, see formNodeOf). Other forms
+// on the page (e.g. the login/logout form in the header) aren't part of it.
+func parseBodyForForm(bodyBytes []byte, page string) htmlForm {
+	return parseBodyForFormWith(bodyBytes, page, nil)
+}
+
+// parseBodyForFormWith is parseBodyForForm, but prefers the form that
+// contains an input that control returns true for (if control isn't nil)
+func parseBodyForFormWith(bodyBytes []byte, page string, control func(htmlInput) bool) htmlForm {
+	doc := parseHTML(bodyBytes)
+	formNode := formNodeOf(doc, page, control)
+
+	return htmlForm{
+		inputs:  inputsOfNode(formNode),
+		selects: selectsOfNode(formNode),
+		labels:  labelsOfNode(doc),
+	}
+}
+
+// formNodeOf returns the form element under doc to submit. If there is more
+// than one, it is the one that contains an input control returns true for
+// (if control isn't nil), then the one whose action is page (by file name,
+// an empty action is the page itself), then the one with a CSRF token, and
+// then the one with the most fields. Forms without fields are ignored. If
+// there is no form with fields, doc is returned (e.g. if the page's fields
+// aren't inside of its form element).
+func formNodeOf(doc *html.Node, page string, control func(htmlInput) bool) *html.Node {
+	type rank struct {
+		hasControl   bool
+		actionIsPage bool
+		hasCSRFToken bool
+		fields       int
+	}
+	better := func(a, b rank) bool {
+		switch {
+		case a.hasControl != b.hasControl:
+			return a.hasControl
+		case a.actionIsPage != b.actionIsPage:
+			return a.actionIsPage
+		case a.hasCSRFToken != b.hasCSRFToken:
+			return a.hasCSRFToken
+		}
+		return a.fields > b.fields
+	}
+
+	var best *html.Node
+	bestRank := rank{}
+	for _, formEl := range findElements(doc, atom.Form) {
+		r := rank{}
+
+		for _, input := range inputsOfNode(formEl) {
+			if input["name"] == "" {
+				continue
+			}
+			r.fields++
+
+			if input["name"] == "CSRFToken" {
+				r.hasCSRFToken = true
+			}
+			if control != nil && control(input) {
+				r.hasControl = true
+			}
+		}
+		for _, sel := range selectsOfNode(formEl) {
+			if sel.attrs["name"] != "" {
+				r.fields++
+			}
+		}
+		if r.fields <= 0 {
+			continue
+		}
+
+		action := nodeAttrs(formEl)["action"]
+		if action == "" {
+			r.actionIsPage = true
+		} else if u, err := url.Parse(action); err == nil && page != "" && path.Base(u.Path) == path.Base(page) {
+			r.actionIsPage = true
+		}
+
+		if best == nil || better(r, bestRank) {
+			best = formEl
+			bestRank = r
+		}
+	}
+
+	if best == nil {
+		return doc
+	}
+
+	return best
+}
+
+// values returns the values a browser would submit for the form if the user
+// changed nothing
+func (form htmlForm) values() url.Values {
+	data := url.Values{}
+
+	for _, input := range form.inputs {
+		name := input["name"]
+		if name == "" {
+			continue
+		}
+
+		_, disabled := input["disabled"]
+		if disabled {
+			continue
+		}
+
+		switch strings.ToLower(input["type"]) {
+		case "checkbox", "radio":
+			// only submitted if checked, value defaults to "on"
+			_, checked := input["checked"]
+			if !checked {
+				continue
+			}
+
+			value, hasValue := input["value"]
+			if !hasValue {
+				value = "on"
+			}
+			data.Add(name, value)
+
+		case "submit", "button", "image", "reset", "file":
+			// never part of the form values
+
+		default:
+			// hidden, text, password, etc.
+			data.Add(name, input["value"])
+		}
+	}
+
+	for _, sel := range form.selects {
+		if sel.attrs["name"] == "" {
+			continue
+		}
+
+		_, disabled := sel.attrs["disabled"]
+		if disabled {
+			continue
+		}
+
+		data.Set(sel.attrs["name"], sel.selectedValue())
+	}
+
+	return data
+}
+
+// fileInputName returns the name of the form's first file input
+func (form htmlForm) fileInputName() (name string, found bool) {
+	for _, input := range inputsOfType(form.inputs, "file") {
+		if input["name"] != "" {
+			return input["name"], true
+		}
+	}
+
+	return "", false
+}
+
+// checkboxesByLabel returns the named checkboxes whose label matches regex,
+// in document order
+func (form htmlForm) checkboxesByLabel(regex *regexp.Regexp) []htmlInput {
+	matches := []htmlInput{}
+	for _, input := range inputsOfType(form.inputs, "checkbox") {
+		if input["name"] == "" {
+			continue
+		}
+
+		if regex.MatchString(form.labels[input["id"]]) {
+			matches = append(matches, input)
+		}
+	}
+
+	return matches
+}
+
+// selectByName returns the select with the specified name
+func (form htmlForm) selectByName(name string) (sel htmlSelect, found bool) {
+	for _, sel := range form.selects {
+		if sel.attrs["name"] == name {
+			return sel, true
+		}
+	}
+
+	return htmlSelect{}, false
+}
+
+// selectByLabel returns the first named select whose label matches regex
+func (form htmlForm) selectByLabel(regex *regexp.Regexp) (sel htmlSelect, found bool) {
+	for _, sel := range form.selects {
+		if sel.attrs["name"] == "" {
+			continue
+		}
+
+		if regex.MatchString(form.labels[sel.attrs["id"]]) {
+			return sel, true
+		}
+	}
+
+	return htmlSelect{}, false
+}
+
+// parseBodyForFormValues returns the values a browser would submit for the
+// form contained in the html response that is submitted to page (see
+// parseBodyForForm) if the user changed nothing
+func parseBodyForFormValues(bodyBytes []byte, page string) url.Values {
+	return parseBodyForForm(bodyBytes, page).values()
+}
@@ -0,0 +1,76 @@
+package printer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readTestPage returns the contents of the web UI page name in testdata
+func readTestPage(t *testing.T, name string) []byte {
+	t.Helper()
+
+	bodyBytes, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read test page (%s)", err)
+	}
+
+	return bodyBytes
+}
+
+func TestParseBodyForFormValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		page   string
+		action string
+		want   map[string]string
+	}{
+		{
+			name:   "delete confirmation (not the logout form)",
+			page:   "delete.html",
+			action: defaultProfile.URLs.CertDelete,
+			want: map[string]string{
+				"CSRFToken":              "RGVsZXRlLVRva2VuPT0=",
+				"pageid":                 "383",
+				"hidden_certificate_idx": "3",
+			},
+		},
+		{
+			name:   "import (file and submit aren't values)",
+			page:   "import.html",
+			action: defaultProfile.URLs.CertImport,
+			want: map[string]string{
+				"CSRFToken": "SW1wb3J0LVRva2VuPT0=",
+				"pageid":    "390",
+				"B821":      "",
+			},
+		},
+		{
+			name:   "http settings (checked boxes and selected option)",
+			page:   "http.html",
+			action: defaultProfile.URLs.HttpSettings,
+			want: map[string]string{
+				"CSRFToken": "SHR0cC1Ub2tlbj09",
+				"pageid":    "326",
+				"B903":      "3",
+				"B86c":      "1",
+				"B87d":      "1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := parseBodyForFormValues(readTestPage(t, tt.page), tt.action)
+
+			if len(data) != len(tt.want) {
+				t.Errorf("got %d values %v, want %d", len(data), data, len(tt.want))
+			}
+			for name, value := range tt.want {
+				if !data.Has(name) || data.Get(name) != value {
+					t.Errorf("value of %s is '%s' (present: %t), want '%s'", name, data.Get(name), data.Has(name), value)
+				}
+			}
+		})
+	}
+}
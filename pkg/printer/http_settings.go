@@ -6,15 +6,42 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 )
 
-const urlHttpCertServerSettings = "net/net/certificate/http.html"
-
 var (
 	errCurrentCertIdNotFound = errors.New("printer: get: failed to find current cert id")
+	errHttpFieldsNotFound    = errors.New("printer: http settings: http (port 80) fields not found")
+	errHttpRedirectNotFound  = errors.New("printer: http settings: redirect to https field not found (model may not support it)")
+	errHttpSettingsRefused   = errors.New("printer: http settings: printer refused the settings (showed the settings form again)")
+
+	// label of the plain HTTP checkboxes, e.g. `HTTP(Port80)` or `HTTP (Port 80)`
+	regexHttpPort80Label = regexp.MustCompile(`(?i)^http\s*\(\s*port\s*80\s*\)`)
+
+	// label of the redirect checkbox, e.g. `Redirect HTTP to HTTPS`
+	regexHttpRedirectLabel = regexp.MustCompile(`(?i)redirect`)
+
+	// label of the HTTPS checkboxes, e.g. `HTTPS(Port443)` or `HTTPS (Port 443)`
+	regexHttpsPort443Label = regexp.MustCompile(`(?i)^https\s*\(\s*port\s*443\s*\)`)
+
+	// label of the cert select, e.g. `Select the Certificate`
+	regexHttpCertLabel = regexp.MustCompile(`(?i)certificate`)
 )
 
+// HttpSettings are the HTTPS and HTTP settings on the HTTP Server Settings page
+type HttpSettings struct {
+	// HttpsWebUI is true if HTTPS is enabled for the WebUI
+	HttpsWebUI bool
+	// HttpsIPP is true if IPP over TLS (IPPS) is enabled
+	HttpsIPP bool
+	// PlainHttp is true if plain HTTP (port 80) is enabled for the WebUI or IPP
+	PlainHttp bool
+	// RedirectHttp is true if plain HTTP is redirected to HTTPS (always false
+	// if the model does not support it)
+	RedirectHttp bool
+}
+
 // getHttpSettings fetches the HTTP Server Settings page
 func (p *printer) getHttpSettings() ([]byte, error) {
 	// get url & set path
@@ -22,7 +49,7 @@ func (p *printer) getHttpSettings() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	u.Path = urlHttpCertServerSettings
+	u.Path = p.profile.URLs.HttpSettings
 
 	// make and do request
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
@@ -30,7 +57,7 @@ func (p *printer) getHttpSettings() ([]byte, error) {
 		return nil, err
 	}
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -44,47 +71,104 @@ func (p *printer) getHttpSettings() ([]byte, error) {
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("printer: get of http settings page failed (status code %d)", resp.StatusCode)
+		return nil, newStatusError("get of http settings page", resp)
 	}
 
 	return bodyBytes, nil
 }
 
-// SetActiveCert sets the printers active certificate the specified ID and
-// then restarts the printer (to make the new cert active)
-// Note: This function even works of the `id` is not in the dropdown box of the printer's
-// cert picker (which happens when the cert does not have a Common Name)
-func (p *printer) SetActiveCert(id string) error {
-	// GET http settings
+// httpPort80FieldNames returns the names of the plain HTTP (port 80) checkboxes
+// on the HTTP Server Settings page (e.g. for the WebUI and IPP)
+func httpPort80FieldNames(form htmlForm) []string {
+	names := []string{}
+	for _, input := range form.checkboxesByLabel(regexHttpPort80Label) {
+		names = append(names, input["name"])
+	}
+
+	return names
+}
+
+// httpRedirectField returns the redirect to HTTPS checkbox on the HTTP Server
+// Settings page. found is false if the model does not have this option.
+func httpRedirectField(form htmlForm) (input htmlInput, found bool) {
+	inputs := form.checkboxesByLabel(regexHttpRedirectLabel)
+	if len(inputs) <= 0 {
+		return nil, false
+	}
+
+	return inputs[0], true
+}
+
+// httpsFieldNames returns the names of the HTTPS checkboxes for the WebUI and
+// IPP on the HTTP Server Settings page. They are the two checkboxes labeled
+// as HTTPS, in that order. If they can't be found, the profile's names are
+// used.
+func (p *printer) httpsFieldNames(form htmlForm) (webUI, ipp string) {
+	inputs := form.checkboxesByLabel(regexHttpsPort443Label)
+	if len(inputs) != 2 {
+		return p.profile.Fields.HttpsWebUI, p.profile.Fields.HttpsIPP
+	}
+
+	return inputs[0]["name"], inputs[1]["name"]
+}
+
+// httpCertFieldName returns the name of the cert select on the HTTP Server
+// Settings page. If it can't be found, the profile's name is used.
+func (p *printer) httpCertFieldName(form htmlForm) string {
+	sel, found := form.selectByLabel(regexHttpCertLabel)
+	if !found {
+		return p.profile.Fields.HttpSettingsCert
+	}
+
+	return sel.attrs["name"]
+}
+
+// GetHttpSettings returns the printer's current HTTPS and HTTP settings
+func (p *printer) GetHttpSettings() (HttpSettings, error) {
 	bodyBytes, err := p.getHttpSettings()
 	if err != nil {
-		return err
+		return HttpSettings{}, err
 	}
 
-	// find CSRFToken
-	csrfToken, err := parseBodyForCSRFToken(bodyBytes)
-	if err != nil {
-		return err
+	form := parseBodyForForm(bodyBytes, p.profile.URLs.HttpSettings)
+
+	httpFields := httpPort80FieldNames(form)
+	if len(httpFields) <= 0 {
+		return HttpSettings{}, errHttpFieldsNotFound
 	}
 
-	// submit initial form to change the cert
-	data := url.Values{}
-	data.Set("pageid", "326")
-	data.Set("CSRFToken", csrfToken)
-	data.Set("B903", id)
-	// B91d always seems to be 1, but wasn't needed here
-	// Enable HTTPS for WebUI and IPP
-	data.Set("B86c", "1")
-	data.Set("B87e", "1")
-	// there are some other values here but don't set them (which should
-	// leave them as-is in most cases)
+	// checked checkboxes are the ones in the form values
+	data := form.values()
+	httpsWebUIField, httpsIPPField := p.httpsFieldNames(form)
+
+	settings := HttpSettings{
+		HttpsWebUI: data.Has(httpsWebUIField),
+		HttpsIPP:   data.Has(httpsIPPField),
+	}
 
+	for _, name := range httpFields {
+		if data.Has(name) {
+			settings.PlainHttp = true
+		}
+	}
+
+	redirectField, found := httpRedirectField(form)
+	if found {
+		settings.RedirectHttp = data.Has(redirectField["name"])
+	}
+
+	return settings, nil
+}
+
+// submitHttpSettings posts data to the HTTP Server Settings page and then
+// submits the confirmation form, which restarts the printer
+func (p *printer) submitHttpSettings(data url.Values, activateOtherProtocols bool) error {
 	// get url & set path
 	u, err := url.ParseRequestURI(p.baseUrl)
 	if err != nil {
 		return err
 	}
-	u.Path = urlHttpCertServerSettings
+	u.Path = p.profile.URLs.HttpSettings
 
 	// make and do request
 	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
@@ -94,43 +178,49 @@ func (p *printer) SetActiveCert(id string) error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	// read body of response
-	bodyBytes, err = io.ReadAll(resp.Body)
+	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("printer: failed to post set active cert form")
+		return withPageMessages(newStatusError("post of http settings form", resp), bodyBytes)
+	}
+
+	// the printer shows the settings form again (with a message) instead of
+	// the confirmation if it refused the settings
+	if len(httpPort80FieldNames(parseBodyForForm(bodyBytes, p.profile.URLs.HttpSettings))) > 0 {
+		return withPageMessages(errHttpSettingsRefused, bodyBytes)
 	}
 
 	// find next CSRFToken
-	csrfToken, err = parseBodyForCSRFToken(bodyBytes)
+	csrfToken, err := parseBodyForCSRFToken(bodyBytes)
 	if err != nil {
-		return err
+		return withPageMessages(err, bodyBytes)
 	}
 
-	// submit confirmation (& reboot now)
-	data = url.Values{}
-	data.Set("pageid", "326")
+	// submit confirmation (& reboot now), with the confirmation page's own
+	// hidden fields
+	data = parseBodyForFormValues(bodyBytes, p.profile.URLs.HttpSettings)
+	if data.Get("pageid") == "" {
+		data.Set("pageid", p.profile.PageIDs.HttpSettings)
+	}
 	data.Set("CSRFToken", csrfToken)
 	// 4 == do NOT activate other secure protos
 	// 5 == DO activate other secure protos
-	data.Set("http_page_mode", "5")
-
-	// get url & set path
-	u, err = url.ParseRequestURI(p.baseUrl)
-	if err != nil {
-		return err
+	if activateOtherProtocols {
+		data.Set("http_page_mode", "5")
+	} else {
+		data.Set("http_page_mode", "4")
 	}
-	u.Path = urlHttpCertServerSettings
 
 	// make and do request
 	req, err = http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
@@ -140,18 +230,197 @@ func (p *printer) SetActiveCert(id string) error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err = p.httpClient.Do(req)
+	resp, err = p.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	// read body of response
-	_, _ = io.Copy(io.Discard, resp.Body)
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
 
 	// OK status?
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("printer: failed to post set active cert form")
+		return withPageMessages(newStatusError("post of http settings confirmation form", resp), bodyBytes)
+	}
+
+	return nil
+}
+
+// ActivateCertOptions are the options for SetActiveCert. Nil values are left
+// as they currently are on the printer.
+type ActivateCertOptions struct {
+	// HttpsWebUI enables or disables HTTPS for the WebUI
+	HttpsWebUI *bool
+	// HttpsIPP enables or disables IPP over TLS (IPPS)
+	HttpsIPP *bool
+	// ActivateOtherProtocols also activates the other protocols that have
+	// secure settings. If false, the other protocols are left as-is.
+	ActivateOtherProtocols bool
+}
+
+// setCheckbox sets (checked) or removes (unchecked) the checkbox name in
+// data, unless checked is nil
+func setCheckbox(data url.Values, name string, checked *bool) {
+	if checked == nil {
+		return
+	}
+
+	if *checked {
+		data.Set(name, "1")
+	} else {
+		// unchecked checkboxes are not submitted
+		data.Del(name)
+	}
+}
+
+// HttpSettingsChange is a change to the HTTP Server Settings page, which
+// restarts the printer. Nil (or empty) values are left as they currently are
+// on the printer.
+type HttpSettingsChange struct {
+	// CertID is the id of the cert to make active
+	CertID string
+	// HttpsWebUI enables or disables HTTPS for the WebUI
+	HttpsWebUI *bool
+	// HttpsIPP enables or disables IPP over TLS (IPPS)
+	HttpsIPP *bool
+	// PlainHttp enables or disables plain HTTP (port 80) for the WebUI and IPP
+	PlainHttp *bool
+	// RedirectHttp enables or disables the redirect of plain HTTP to HTTPS
+	// (not all models support this)
+	RedirectHttp *bool
+	// ActivateOtherProtocols also activates the other protocols that have
+	// secure settings. If false, the other protocols are left as-is.
+	ActivateOtherProtocols bool
+}
+
+// ChangeHttpSettings makes change to the HTTP Server Settings and then
+// restarts the printer (once, no matter how many settings are changed).
+// Turning off plain HTTP while HTTPS for the WebUI is off is refused (it
+// would lock out the WebUI).
+func (p *printer) ChangeHttpSettings(change HttpSettingsChange) error {
+	// GET http settings
+	bodyBytes, err := p.getHttpSettings()
+	if err != nil {
+		return err
+	}
+
+	// find CSRFToken
+	csrfToken, err := parseBodyForCSRFToken(bodyBytes)
+	if err != nil {
+		return err
+	}
+
+	// start from the current form values (including the page's own hidden
+	// fields) so anything not specified is unchanged
+	form := parseBodyForForm(bodyBytes, p.profile.URLs.HttpSettings)
+	data := form.values()
+	if data.Get("pageid") == "" {
+		data.Set("pageid", p.profile.PageIDs.HttpSettings)
+	}
+	data.Set("CSRFToken", csrfToken)
+	if change.CertID != "" {
+		data.Set(p.httpCertFieldName(form), change.CertID)
+	}
+
+	// HTTPS for WebUI and IPP
+	httpsWebUIField, httpsIPPField := p.httpsFieldNames(form)
+	setCheckbox(data, httpsWebUIField, change.HttpsWebUI)
+	setCheckbox(data, httpsIPPField, change.HttpsIPP)
+
+	// plain HTTP
+	if change.PlainHttp != nil {
+		httpFields := httpPort80FieldNames(form)
+		if len(httpFields) <= 0 {
+			return errHttpFieldsNotFound
+		}
+
+		for _, name := range httpFields {
+			setCheckbox(data, name, change.PlainHttp)
+		}
+	}
+
+	if change.RedirectHttp != nil {
+		redirectField, found := httpRedirectField(form)
+		if !found {
+			return errHttpRedirectNotFound
+		}
+
+		// checked checkbox value defaults to "on"
+		value, hasValue := redirectField["value"]
+		if !hasValue {
+			value = "on"
+		}
+
+		if *change.RedirectHttp {
+			data.Set(redirectField["name"], value)
+		} else {
+			data.Del(redirectField["name"])
+		}
+	}
+
+	// never lock out the WebUI
+	plainHttpOff := change.PlainHttp != nil && !*change.PlainHttp
+	redirectOn := change.RedirectHttp != nil && *change.RedirectHttp
+	if (plainHttpOff || redirectOn) && !data.Has(httpsWebUIField) {
+		return errors.New("printer: http settings: https for the webui must be on when plain http is off or redirected")
+	}
+
+	return p.submitHttpSettings(data, change.ActivateOtherProtocols)
+}
+
+// SetActiveCert sets the printers active certificate the specified ID and
+// then restarts the printer (to make the new cert active)
+// Note: This function even works of the `id` is not in the dropdown box of the printer's
+// cert picker (which happens when the cert does not have a Common Name)
+func (p *printer) SetActiveCert(id string, opts ActivateCertOptions) error {
+	err := p.ChangeHttpSettings(HttpSettingsChange{
+		CertID:                 id,
+		HttpsWebUI:             opts.HttpsWebUI,
+		HttpsIPP:               opts.HttpsIPP,
+		ActivateOtherProtocols: opts.ActivateOtherProtocols,
+	})
+	if err != nil {
+		return fmt.Errorf("printer: failed to set active cert (%w)", err)
+	}
+
+	return nil
+}
+
+// DisableHttp turns off plain HTTP (port 80) access to the WebUI and IPP,
+// leaving HTTPS for the WebUI enabled (IPPS is left as-is), and then restarts
+// the printer. HTTPS must already be working with a valid certificate or the
+// WebUI will be unreachable.
+func (p *printer) DisableHttp() error {
+	on, off := true, false
+
+	err := p.ChangeHttpSettings(HttpSettingsChange{
+		HttpsWebUI: &on,
+		PlainHttp:  &off,
+	})
+	if err != nil {
+		return fmt.Errorf("printer: failed to disable http (%w)", err)
+	}
+
+	return nil
+}
+
+// RedirectHttp turns on the redirect of plain HTTP requests to HTTPS, leaving
+// HTTPS enabled, and then restarts the printer. Not all models support this.
+// HTTPS must already be working with a valid certificate or the WebUI will be
+// unreachable.
+func (p *printer) RedirectHttp() error {
+	on := true
+
+	err := p.ChangeHttpSettings(HttpSettingsChange{
+		HttpsWebUI:   &on,
+		RedirectHttp: &on,
+	})
+	if err != nil {
+		return fmt.Errorf("printer: failed to enable redirect to https (%w)", err)
 	}
 
 	return nil
@@ -0,0 +1,100 @@
+package printer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHttpSettingsForm(t *testing.T) {
+	p := &printer{profile: defaultProfile}
+
+	tests := []struct {
+		name          string
+		body          []byte
+		wantCertField string
+		wantSelected  string
+		wantOptions   []htmlOption
+		wantPort80    []string
+		wantWebUI     string
+		wantIPP       string
+	}{
+		{
+			name:          "http settings page",
+			body:          readTestPage(t, "http.html"),
+			wantCertField: "B903",
+			wantSelected:  "3",
+			wantOptions: []htmlOption{
+				{value: "0", label: "Preset"},
+				{value: "3", label: "printer.example.com 2025", selected: true},
+				{value: "5", label: "printer example com (renewed)"},
+			},
+			wantPort80: []string{"B86b", "B87d"},
+			wantWebUI:  "B86c",
+			wantIPP:    "B87e",
+		},
+		{
+			name: "unlabelled fields use the profile's names",
+			body: []byte(`<form action="http.html">` +
+				`<select name=B903><option value=0>Preset</option><option value=7>new cert name</option></select>` +
+				`<input type=checkbox name=B86c checked><input type=checkbox name=B87e>` +
+				`</form>`),
+			wantCertField: "B903",
+			wantSelected:  "0",
+			wantOptions: []htmlOption{
+				{value: "0", label: "Preset"},
+				{value: "7", label: "new cert name"},
+			},
+			wantPort80: []string{},
+			wantWebUI:  "B86c",
+			wantIPP:    "B87e",
+		},
+		{
+			name: "option value defaults to its text",
+			body: []byte(`<form action='http.html'>` +
+				`<label for='certSel'>Select the Certificate</label>` +
+				`<select id='certSel' name='Bc01'><option>Preset</option><option selected>my cert</option></select>` +
+				`</form>`),
+			wantCertField: "Bc01",
+			wantSelected:  "my cert",
+			wantOptions: []htmlOption{
+				{value: "Preset", label: "Preset"},
+				{value: "my cert", label: "my cert", selected: true},
+			},
+			wantPort80: []string{},
+			wantWebUI:  "B86c",
+			wantIPP:    "B87e",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := parseBodyForForm(tt.body, p.profile.URLs.HttpSettings)
+
+			certField := p.httpCertFieldName(form)
+			if certField != tt.wantCertField {
+				t.Fatalf("cert field is '%s', want '%s'", certField, tt.wantCertField)
+			}
+
+			sel, found := form.selectByName(certField)
+			if !found {
+				t.Fatalf("cert select '%s' not found", certField)
+			}
+			if !reflect.DeepEqual(sel.options, tt.wantOptions) {
+				t.Errorf("options are %+v, want %+v", sel.options, tt.wantOptions)
+			}
+			if sel.selectedValue() != tt.wantSelected {
+				t.Errorf("selected value is '%s', want '%s'", sel.selectedValue(), tt.wantSelected)
+			}
+
+			port80 := httpPort80FieldNames(form)
+			if !reflect.DeepEqual(port80, tt.wantPort80) {
+				t.Errorf("http (port 80) fields are %v, want %v", port80, tt.wantPort80)
+			}
+
+			webUI, ipp := p.httpsFieldNames(form)
+			if webUI != tt.wantWebUI || ipp != tt.wantIPP {
+				t.Errorf("https fields are '%s' and '%s', want '%s' and '%s'", webUI, ipp, tt.wantWebUI, tt.wantIPP)
+			}
+		})
+	}
+}
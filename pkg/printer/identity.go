@@ -0,0 +1,133 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	errIdentityMismatch = errors.New("printer: identity does not match the expected identity (wrong device?)")
+
+	// e.g. `Serial no.`, `Seriennummer`, or `Numéro de série`
+	regexSerialTerm = regexp.MustCompile(`(?i)serial|seriennummer|s[ée]rie`)
+
+	// e.g. `Node Name`, `Knotenname`, or `Nom du nœud`
+	regexNodeNameTerm = regexp.MustCompile(`(?i)node\s*name|knotenname|nom\s+du\s+n(?:œ|oe)ud`)
+
+	// e.g. `MAC Address`
+	regexMACTerm = regexp.MustCompile(`(?i)\bmac\b`)
+
+	// e.g. `00:80:77:12:34:56` or `00-80-77-12-34-56`
+	regexMACValue = regexp.MustCompile(`^(?:[0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}$`)
+)
+
+// Identity identifies a specific printer device. When used as the expected
+// identity in Config, empty values aren't checked.
+type Identity struct {
+	SerialNumber string
+	MACAddress   string
+	NodeName     string
+}
+
+// isEmpty returns true if none of identity's values are set
+func (identity Identity) isEmpty() bool {
+	return identity.SerialNumber == "" && identity.MACAddress == "" && identity.NodeName == ""
+}
+
+// normalizeMAC returns mac in lower case without separators
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+}
+
+// GetIdentity reads the printer's serial number, MAC address, and node name
+// from its information and network status pages. Values that weren't found
+// are empty.
+func (p *printer) GetIdentity() (Identity, error) {
+	// terms and values (of both pages) are in definition lists or two column
+	// tables
+	defs := []htmlDefinition{}
+	var lastErr error
+	found := false
+	for _, path := range []string{p.profile.URLs.Information, p.profile.URLs.NetworkStatus} {
+		bodyBytes, err := p.getPage(path, "device identity")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		found = true
+
+		defs = append(defs, parseBodyForDefinitions(bodyBytes)...)
+		for _, table := range parseBodyForTables(bodyBytes) {
+			for _, row := range table.rows {
+				if len(row.cells) >= 2 {
+					defs = append(defs, htmlDefinition{term: row.cells[0], description: row.cells[1]})
+				}
+			}
+		}
+	}
+
+	if !found {
+		return Identity{}, lastErr
+	}
+
+	identity := Identity{}
+	for _, def := range defs {
+		switch {
+		case identity.MACAddress == "" && regexMACTerm.MatchString(def.term) && def.description != "":
+			identity.MACAddress = def.description
+		case identity.SerialNumber == "" && regexSerialTerm.MatchString(def.term):
+			identity.SerialNumber = def.description
+		case identity.NodeName == "" && regexNodeNameTerm.MatchString(def.term):
+			identity.NodeName = def.description
+		}
+	}
+
+	// an unlabelled (e.g. translated) MAC address is found by its shape, but
+	// only if no labelled one was found, since other values (e.g. a wireless
+	// peer's address) may have the same shape
+	if identity.MACAddress == "" {
+		for _, def := range defs {
+			if regexMACValue.MatchString(def.description) {
+				identity.MACAddress = def.description
+				break
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// verifyIdentity returns an error if the printer's identity doesn't match
+// expected. A value that is expected but can't be read from the printer is
+// also a mismatch.
+func (p *printer) verifyIdentity(expected Identity) error {
+	actual, err := p.GetIdentity()
+	if err != nil {
+		return fmt.Errorf("printer: failed to read identity (%w)", err)
+	}
+
+	checks := []struct {
+		name     string
+		expected string
+		actual   string
+		equal    bool
+	}{
+		{"serial number", expected.SerialNumber, actual.SerialNumber, strings.EqualFold(expected.SerialNumber, actual.SerialNumber)},
+		{"mac address", expected.MACAddress, actual.MACAddress, normalizeMAC(expected.MACAddress) == normalizeMAC(actual.MACAddress)},
+		{"node name", expected.NodeName, actual.NodeName, strings.EqualFold(expected.NodeName, actual.NodeName)},
+	}
+
+	for _, check := range checks {
+		if check.expected == "" {
+			continue
+		}
+
+		if check.actual == "" || !check.equal {
+			return fmt.Errorf("%w (%s: expected '%s', found '%s')", errIdentityMismatch, check.name, check.expected, check.actual)
+		}
+	}
+
+	return nil
+}
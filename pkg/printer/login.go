@@ -2,51 +2,122 @@ package printer
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
-const urlLogin = "/general/status.html"
-
 var (
-	errLoginNoAuth           = errors.New("printer: login: no auth cookie received (wrong password?)")
-	errPasswordFieldNotFound = errors.New("printer: login: password field not found in login form")
+	// ErrWrongPassword means the printer refused the password. A refused
+	// password isn't sent to the same printer again by this process for a
+	// while (later logins fail with this error without contacting the
+	// printer), since repeated failures lock out the printer's login.
+	ErrWrongPassword = fmt.Errorf("%w (wrong password)", ErrAuthFailed)
+
+	// ErrLockedOut means the printer's login is locked out (usually after too
+	// many failed logins). The error is a *LockoutError.
+	ErrLockedOut = fmt.Errorf("%w (login is locked out)", ErrAuthFailed)
+
+	// ErrLoginFormNotFound means the login page doesn't contain the login form
+	ErrLoginFormNotFound = errors.New("printer: login: password field not found in login form")
+
+	// e.g. `Login is locked. Please try again later.` or `Anmeldung gesperrt`
+	regexLockoutMessage = regexp.MustCompile(`(?i)\block(?:ed|out)\b|\bgesperrt|\bverrouill|\bbloqu|\bbloccat|\bgeblokkeerd|\bvergrendeld`)
+
+	// e.g. `5 minutes` or `30 Sekunden`
+	regexLockoutDuration = regexp.MustCompile(`(?i)(\d+)\s*(sec|second|sekunde|seconde|segundo|min|minute|minuut|minuto|hour|stunde|heure|hora|ora|uur)`)
 )
 
+// LockoutError is the error of a login refused because the printer's login is
+// locked out. It matches ErrLockedOut (and ErrAuthFailed) with errors.Is.
+type LockoutError struct {
+	Hostname string
+	// Message is the printer's lockout message
+	Message string
+	// Until is when the lockout is expected to end (from the message, or
+	// lockoutDefaultWait if the message doesn't say)
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("printer: login: login is locked out until about %s (%s)", e.Until.Format(time.RFC3339), e.Message)
+}
+
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrLockedOut || target == ErrAuthFailed
+}
+
+// parseLockout returns the lockout shown on a login page, or nil if the page
+// doesn't show one
+func parseLockout(hostname string, bodyBytes []byte) *LockoutError {
+	messages := parseBodyForTextMatching(bodyBytes, regexLockoutMessage)
+	if len(messages) <= 0 {
+		return nil
+	}
+
+	lockout := &LockoutError{
+		Hostname: hostname,
+		Message:  messages[0],
+		Until:    time.Now().Add(lockoutDefaultWait),
+	}
+
+	// duration in the message?
+	match := regexLockoutDuration.FindStringSubmatch(lockout.Message)
+	if match != nil {
+		amount, err := strconv.Atoi(match[1])
+		if err == nil {
+			unit := time.Minute
+			switch strings.ToLower(match[2]) {
+			case "sec", "second", "sekunde", "seconde", "segundo":
+				unit = time.Second
+			case "hour", "stunde", "heure", "hora", "ora", "uur":
+				unit = time.Hour
+			}
+			lockout.Until = time.Now().Add(time.Duration(amount) * unit)
+		}
+	}
+
+	return lockout
+}
+
 // parsePasswordFieldName returns the name attribute of the password input field
 // from the HTML login form
 func parsePasswordFieldName(bodyBytes []byte) (fieldName string, err error) {
 	// Look for input elements with type="password"
-	// This regex handles both orders: type first or name first
-	// e.g. <input type="password" name="Baf9" ... /> or <input name="Baf9" type="password" ... />
-	regex := regexp.MustCompile(`<input[^>]+(?:type="password"[^>]+name="([^"]+)"[^>]*|name="([^"]+)"[^>]+type="password"[^>]*)>`)
-	caps := regex.FindSubmatch(bodyBytes)
-
-	// error if didn't find what was expected
-	if len(caps) < 2 {
-		return "", errPasswordFieldNotFound
+	// e.g. <input type="password" name="Baf9" ... />
+	for _, input := range inputsOfType(parseBodyForInputs(bodyBytes), "password") {
+		if input["name"] != "" {
+			return input["name"], nil
+		}
 	}
 
-	// return the non-empty capture group (either caps[1] or caps[2])
-	if len(caps[1]) > 0 {
-		return string(caps[1]), nil
-	}
-	return string(caps[2]), nil
+	return "", ErrLoginFormNotFound
 }
 
 // login performs the login command against the remote printer. it is
 // used internally as part of the printer creation process to ensure
-// credentials are valid
+// credentials are valid. It fails with ErrWrongPassword, a *LockoutError, or
+// ErrLoginFormNotFound if the printer refuses the login (or a *StatusError or
+// *UnreachableError if it can't answer), and it doesn't contact the printer if
+// that is already known to happen.
 func (p *printer) login(password string) error {
+	// never re-send a refused password or try during a lockout
+	err := logins.check(p.hostname, password)
+	if err != nil {
+		return err
+	}
+
 	// get url & set path
 	u, err := url.ParseRequestURI(p.baseUrl)
 	if err != nil {
 		return err
 	}
-	u.Path = urlLogin
+	u.Path = p.profile.URLs.Login
 
 	// first, fetch the login page to discover the password field name
 	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
@@ -56,7 +127,7 @@ func (p *printer) login(password string) error {
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return err
+		return &UnreachableError{Hostname: p.hostname, Err: err}
 	}
 	defer resp.Body.Close()
 
@@ -69,13 +140,20 @@ func (p *printer) login(password string) error {
 	// parse the password field name from the HTML
 	passwordFieldName, err := parsePasswordFieldName(bodyBytes)
 	if err != nil {
+		// a locked out printer may show its message instead of the form
+		lockout := parseLockout(p.hostname, bodyBytes)
+		if lockout != nil {
+			logins.recordLockout(lockout)
+			return lockout
+		}
+
 		return err
 	}
 
 	// login form values using the discovered field name
 	data := url.Values{}
 	data.Set(passwordFieldName, password)
-	data.Set("loginurl", urlLogin)
+	data.Set("loginurl", p.profile.URLs.Login)
 
 	// make and do login request
 	req, err = http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
@@ -87,12 +165,15 @@ func (p *printer) login(password string) error {
 
 	resp, err = p.httpClient.Do(req)
 	if err != nil {
-		return err
+		return &UnreachableError{Hostname: p.hostname, Err: err}
 	}
 	defer resp.Body.Close()
 
-	// read and discard entire body (should be empty)
-	_, _ = io.Copy(io.Discard, resp.Body)
+	// read body (empty on success, a failure may show a message)
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
 
 	// confirm got cookie
 	foundAuthCookie := false
@@ -103,11 +184,65 @@ func (p *printer) login(password string) error {
 		}
 	}
 	if !foundAuthCookie {
-		return errLoginNoAuth
+		return p.loginRefused(req, resp, bodyBytes, password)
 	}
 
 	// set cookies in jar
 	p.httpClient.Jar.SetCookies(u, resp.Cookies())
+	p.loginFieldName = passwordFieldName
+	logins.recordSuccess(p.hostname)
 
 	return nil
 }
+
+// loginRefused records and returns why the printer refused a login: a
+// lockout if the response (or the page it redirects to) shows one, the wrong
+// password if it shows the login form again, otherwise the *StatusError or
+// *UnreachableError of the response (e.g. a printer that is still booting),
+// which isn't recorded since the password may be right
+func (p *printer) loginRefused(req *http.Request, resp *http.Response, bodyBytes []byte, password string) error {
+	// a failure is a normal page or a redirect to one
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return newStatusError("login", resp)
+	}
+
+	// the message is on the page the response redirects to
+	location, err := resp.Location()
+	if err == nil && resp.StatusCode >= 300 {
+		redirectReq, err := http.NewRequest(http.MethodGet, req.URL.ResolveReference(location).String(), nil)
+		if err != nil {
+			return err
+		}
+
+		redirectResp, err := p.httpClient.Do(redirectReq)
+		if err != nil {
+			return &UnreachableError{Hostname: p.hostname, Err: err}
+		}
+		defer redirectResp.Body.Close()
+
+		if redirectResp.StatusCode != http.StatusOK {
+			return newStatusError("login (get of page redirected to)", redirectResp)
+		}
+
+		bodyBytes, err = io.ReadAll(redirectResp.Body)
+		if err != nil {
+			return err
+		}
+		resp = redirectResp
+	}
+
+	lockout := parseLockout(p.hostname, bodyBytes)
+	if lockout != nil {
+		logins.recordLockout(lockout)
+		return lockout
+	}
+
+	// only the login form shown again means the password was refused
+	_, err = parsePasswordFieldName(bodyBytes)
+	if err != nil {
+		return newStatusError("login (response isn't the login form)", resp)
+	}
+
+	logins.recordWrongPassword(p.hostname, password)
+	return ErrWrongPassword
+}
@@ -0,0 +1,110 @@
+package printer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockoutDefaultWait is how long a lockout is assumed to last when the
+// printer's message doesn't say
+const lockoutDefaultWait = 5 * time.Minute
+
+// badPasswordTTL is how long a refused password isn't sent to the printer
+// again (after which the password may have been changed on the printer)
+const badPasswordTTL = 30 * time.Minute
+
+// loginGuard is the retry policy for logins. It records the passwords each
+// printer refused and the printers that are locked out, so that no retry
+// (re-login after a lost session, the next printer of a fleet run, or the
+// next periodic probe) sends a known bad password or tries during a lockout,
+// either of which would extend or cause a lockout.
+type loginGuard struct {
+	mu sync.Mutex
+	// badPasswords are keyed by badPasswordKey, the value is when the entry
+	// expires
+	badPasswords map[string]time.Time
+	// lockouts are keyed by lower case hostname
+	lockouts map[string]*LockoutError
+}
+
+// logins is the login guard of all printers in this process
+var logins = &loginGuard{
+	badPasswords: make(map[string]time.Time),
+	lockouts:     make(map[string]*LockoutError),
+}
+
+// badPasswordKey returns the key of a hostname's password (the password
+// itself isn't kept)
+func badPasswordKey(hostname, password string) string {
+	hash := sha256.Sum256([]byte(password))
+	return strings.ToLower(hostname) + "/" + hex.EncodeToString(hash[:])
+}
+
+// check returns the error a login to hostname with password would end in,
+// without contacting the printer, or nil if the login may be attempted
+func (g *loginGuard) check(hostname, password string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	lockout, exists := g.lockouts[strings.ToLower(hostname)]
+	if exists {
+		if time.Now().Before(lockout.Until) {
+			return lockout
+		}
+		delete(g.lockouts, strings.ToLower(hostname))
+	}
+
+	key := badPasswordKey(hostname, password)
+	expires, bad := g.badPasswords[key]
+	if bad {
+		if time.Now().Before(expires) {
+			return ErrWrongPassword
+		}
+		delete(g.badPasswords, key)
+	}
+
+	return nil
+}
+
+// recordWrongPassword records that hostname refused password (for
+// badPasswordTTL)
+func (g *loginGuard) recordWrongPassword(hostname, password string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.badPasswords[badPasswordKey(hostname, password)] = time.Now().Add(badPasswordTTL)
+}
+
+// recordLockout records that hostname's login is locked out
+func (g *loginGuard) recordLockout(lockout *LockoutError) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lockouts[strings.ToLower(lockout.Hostname)] = lockout
+}
+
+// recordSuccess clears hostname's lockout
+func (g *loginGuard) recordSuccess(hostname string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.lockouts, strings.ToLower(hostname))
+}
+
+// LockedOut returns the lockout of hostname's login, if this process has
+// seen one that hasn't ended yet. Logins to the printer are refused (without
+// contacting it) until the lockout ends.
+func LockedOut(hostname string) (lockout *LockoutError, locked bool) {
+	logins.mu.Lock()
+	defer logins.mu.Unlock()
+
+	lockout, exists := logins.lockouts[strings.ToLower(hostname)]
+	if !exists || !time.Now().Before(lockout.Until) {
+		return nil, false
+	}
+
+	return lockout, true
+}
@@ -0,0 +1,53 @@
+package printer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePasswordFieldName(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    string
+		wantErr error
+	}{
+		{
+			name: "login page",
+			body: readTestPage(t, "login.html"),
+			want: "B1f8",
+		},
+		{
+			name: "double quotes",
+			body: []byte(`<form><input type="password" id="LogBox" name="Baf9" value=""/></form>`),
+			want: "Baf9",
+		},
+		{
+			name: "unquoted and upper case type",
+			body: []byte(`<form><input type=PASSWORD name=B0c1></form>`),
+			want: "B0c1",
+		},
+		{
+			name:    "password field without a name",
+			body:    []byte(`<form><input type="password" id="LogBox"></form>`),
+			wantErr: ErrLoginFormNotFound,
+		},
+		{
+			name:    "no login form (e.g. already logged in)",
+			body:    readTestPage(t, "list.html"),
+			wantErr: ErrLoginFormNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePasswordFieldName(tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error is %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
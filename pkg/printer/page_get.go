@@ -0,0 +1,50 @@
+package printer
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// getPage fetches the page at path and returns its body. name describes the
+// page for error messages.
+func (p *printer) getPage(path, name string) ([]byte, error) {
+	return p.getPageQuery(path, nil, name)
+}
+
+// getPageQuery fetches the page at path with query (e.g. a cert's idx) and
+// returns its body. name describes the page for error messages.
+func (p *printer) getPageQuery(path string, query url.Values, name string) ([]byte, error) {
+	// get url & set path
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	// make and do request
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// read body of response
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("get of "+name+" page", resp)
+	}
+
+	return bodyBytes, nil
+}
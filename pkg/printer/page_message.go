@@ -0,0 +1,123 @@
+package printer
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The printer shows why it refused a form (e.g. an invalid file, a wrong
+// password, or full storage) as a message on the response page. The message
+// is found by its element's class or id, or by reading like an error, since
+// the text is in the web UI's language.
+
+var (
+	// e.g. `<p class="errorMsg">` or `<div id="warning">`
+	regexMessageMarker = regexp.MustCompile(`(?i)err|warn|caution|alert|msg|message|notice`)
+
+	// e.g. `Invalid file.`, `Ungültige Datei`, or `Erreur`
+	regexMessageText = regexp.MustCompile(`(?i)\berror|\binvalid|\bfailed|\bincorrect|\bcannot\b|\bcan't\b|\bunable\b|\bfull\b|` +
+		`\bfehler|\bungültig|\bfehlgeschlagen|\bnicht möglich|\berreur|\binvalide|échec|\bimpossible\b|` +
+		`\bno válid|\bno se puede|\berrore|\bnon valid|\bimpossibile\b|\bfout\b|\bongeldig|\bmislukt`)
+
+	// e.g. `maximum number .` (nodeText spaces the text of adjacent elements)
+	regexSpaceBeforePunct = regexp.MustCompile(`\s+([.,;:!?])`)
+
+	// e.g. `Storage is full.`, `Maximum number of certificates`, or
+	// `Speicher voll`
+	regexStorageFullMessage = regexp.MustCompile(`(?i)\bfull\b|no (?:more )?(?:free )?space|maximum number|` +
+		`cannot (?:store|register|import) (?:any )?more|\bvoll\b|\bplein|\blleno|\bpieno|\bvol\b`)
+)
+
+// parseBodyForMessages returns the (error or status) messages shown on the
+// html response page, in document order
+func parseBodyForMessages(bodyBytes []byte) []string {
+	messages := []string{}
+	add := func(text string) {
+		text = regexSpaceBeforePunct.ReplaceAllString(text, "$1")
+		if text == "" {
+			return
+		}
+		// skip text that is already part of a message
+		for _, message := range messages {
+			if strings.Contains(message, text) {
+				return
+			}
+		}
+		messages = append(messages, text)
+	}
+
+	// elements marked as messages
+	walkElements(parseHTML(bodyBytes), func(el *html.Node) bool {
+		switch el.DataAtom {
+		case atom.Script, atom.Style, atom.Title, atom.Noscript, atom.A, atom.Nav, atom.Select,
+			atom.Input, atom.Label, atom.Button, atom.Textarea, atom.Form:
+			// a form's class/id isn't a message marker (e.g. `<form id="msgform">`)
+			return el.DataAtom == atom.Form
+		}
+
+		attrs := nodeAttrs(el)
+		if regexMessageMarker.MatchString(attrs["class"]) || regexMessageMarker.MatchString(attrs["id"]) {
+			add(nodeText(el))
+			return false
+		}
+
+		return true
+	})
+
+	// text that reads like an error
+	for _, text := range parseBodyForTextMatching(bodyBytes, regexMessageText) {
+		add(text)
+	}
+
+	return messages
+}
+
+// MessageError is the error of a request the printer refused with a message
+// on the response page. It unwraps to Err (how the refusal was detected, e.g.
+// ErrCertRejected or a *StatusError) and, if a message says the storage is
+// full, ErrStorageFull.
+type MessageError struct {
+	Err      error
+	Messages []string
+}
+
+func (e *MessageError) Error() string {
+	return e.Err.Error() + " (printer says: " + strings.Join(e.Messages, "; ") + ")"
+}
+
+// storageFull returns true if a message says the printer's storage is full
+func (e *MessageError) storageFull() bool {
+	for _, message := range e.Messages {
+		if regexStorageFullMessage.MatchString(message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *MessageError) Unwrap() []error {
+	if e.storageFull() {
+		return []error{e.Err, ErrStorageFull}
+	}
+
+	return []error{e.Err}
+}
+
+// withPageMessages returns err with the messages shown on the response page
+// bodyBytes (as a *MessageError), or err unchanged if the page doesn't show
+// any
+func withPageMessages(err error, bodyBytes []byte) error {
+	messages := parseBodyForMessages(bodyBytes)
+	if len(messages) <= 0 {
+		return err
+	}
+
+	return &MessageError{
+		Err:      err,
+		Messages: messages,
+	}
+}
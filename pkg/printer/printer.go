@@ -1,6 +1,8 @@
 package printer
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
 	"net/http/cookiejar"
 	"time"
@@ -9,7 +11,16 @@ import (
 // printer is a struct to interact with a remote Brother printer
 type printer struct {
 	httpClient *http.Client
+	hostname   string
 	baseUrl    string
+	password   string
+	profile    Profile
+	deviceInfo DeviceInfo
+
+	// loginFieldName is the login form's password field (empty if not logged
+	// in)
+	loginFieldName   string
+	sessionCachePath string
 }
 
 // PrinterConfig contains the information necessary to create a printer
@@ -19,22 +30,38 @@ type Config struct {
 	Password  string
 	UserAgent string
 	UseHttp   bool
+	// RootCAs (optional) are used to verify the printer's certificate instead
+	// of the system roots (e.g. when the printer uses a private CA)
+	RootCAs *x509.CertPool
+	// Profile (optional) is used instead of detecting the printer's model and
+	// choosing a built-in profile
+	Profile *Profile
+	// ExpectedIdentity (optional) is checked after login and, if it doesn't
+	// match, the printer is refused (e.g. so a private key is never sent to a
+	// swapped device or a hijacked hostname)
+	ExpectedIdentity Identity
+	// SessionCachePath (optional) is a json file to save the login session in
+	// (on Close) and reuse it from (in NewPrinter), so consecutive runs don't
+	// need to log in each time
+	SessionCachePath string
 }
 
 // custom transport to add User-Agent
 type printerTransport struct {
 	userAgent string
+	transport http.RoundTripper
 }
 
 func (trans *printerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	// always set user-agent
 	req.Header.Set("User-Agent", trans.userAgent)
 
-	return http.DefaultTransport.RoundTrip(req)
+	return trans.transport.RoundTrip(req)
 }
 
-// NewPrinter creates a new printer from a PrinterConfig
-func NewPrinter(cfg Config) (*printer, error) {
+// NewPrinterNoLogin creates a new printer from a PrinterConfig without logging
+// in. Only functions that don't require auth (e.g. GetCurrentLeafCert) will work.
+func NewPrinterNoLogin(cfg Config) (*printer, error) {
 	baseUrl := "https://" + cfg.Hostname
 	// http instead?
 	if cfg.UseHttp {
@@ -47,6 +74,16 @@ func NewPrinter(cfg Config) (*printer, error) {
 		return nil, err
 	}
 
+	// custom roots?
+	var transport http.RoundTripper = http.DefaultTransport
+	if cfg.RootCAs != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{
+			RootCAs: cfg.RootCAs,
+		}
+		transport = t
+	}
+
 	p := &printer{
 		httpClient: &http.Client{
 			// disable redirect (POSTs return 301 and if client follows it loses the post response)
@@ -59,16 +96,60 @@ func NewPrinter(cfg Config) (*printer, error) {
 			Timeout: 30 * time.Second,
 			Transport: &printerTransport{
 				userAgent: cfg.UserAgent,
+				transport: transport,
 			},
 		},
-		baseUrl: baseUrl,
+		hostname: cfg.Hostname,
+		baseUrl:  baseUrl,
+		password: cfg.Password,
+		profile:  defaultProfile,
+
+		sessionCachePath: cfg.SessionCachePath,
 	}
 
-	// login & get cookie
-	err = p.login(cfg.Password)
+	if cfg.Profile != nil {
+		p.profile = *cfg.Profile
+	}
+
+	return p, nil
+}
+
+// NewPrinter creates a new printer from a PrinterConfig and logs in to it
+func NewPrinter(cfg Config) (*printer, error) {
+	p, err := NewPrinterNoLogin(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	// reuse a cached session?
+	restored := false
+	if p.sessionCachePath != "" {
+		restored, err = p.restoreSession()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// login & get cookie
+	if !restored {
+		err = p.login(p.password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// pick the profile for the model
+	if cfg.Profile == nil {
+		p.detectProfile()
+	}
+
+	// right device?
+	if !cfg.ExpectedIdentity.isEmpty() {
+		err = p.verifyIdentity(cfg.ExpectedIdentity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return p, nil
 }
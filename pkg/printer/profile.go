@@ -0,0 +1,238 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Profile contains the model specific details of a printer's web UI. The
+// built-in profiles are chosen by the model detected after login. A Profile
+// for an unlisted model can be loaded from a json file (see LoadProfileFile).
+type Profile struct {
+	Name string `json:"name"`
+	// Models are regular expressions matched against the detected model name
+	Models []string `json:"models"`
+
+	URLs    ProfileURLs    `json:"urls"`
+	PageIDs ProfilePageIDs `json:"page_ids"`
+	Fields  ProfileFields  `json:"fields"`
+	Waits   ProfileWaits   `json:"waits"`
+
+	// CertLimit is how many certificates (including the Preset) the printer
+	// can store, or 0 if unknown. Installs make room before uploading when the
+	// printer is at its limit.
+	CertLimit int `json:"cert_limit"`
+}
+
+// ProfileURLs are the paths of the web UI pages
+type ProfileURLs struct {
+	Login            string `json:"login"`
+	Information      string `json:"information"`
+	NetworkStatus    string `json:"network_status"`
+	CertList         string `json:"cert_list"`
+	CertView         string `json:"cert_view"`
+	CertImport       string `json:"cert_import"`
+	CertDelete       string `json:"cert_delete"`
+	CACertList       string `json:"ca_cert_list"`
+	CACertView       string `json:"ca_cert_view"`
+	CACertImport     string `json:"ca_cert_import"`
+	HttpSettings     string `json:"http_settings"`
+	TLSSettings      string `json:"tls_settings"`
+	ProtocolSettings string `json:"protocol_settings"`
+	SNMPSettings     string `json:"snmp_settings"`
+	AdminPassword    string `json:"admin_password"`
+}
+
+// ProfilePageIDs are the pageid values submitted with the web UI forms, if
+// the page doesn't have its own pageid field
+type ProfilePageIDs struct {
+	CertImport   string `json:"cert_import"`
+	CertDelete   string `json:"cert_delete"`
+	HttpSettings string `json:"http_settings"`
+}
+
+// ProfileFields are the names of the web UI form fields. Fields are found on
+// the page by their type or label and these names are only used if that
+// fails.
+type ProfileFields struct {
+	// CertImportFile is the file input of the cert import form
+	CertImportFile string `json:"cert_import_file"`
+	// HttpSettingsCert is the cert select of the HTTP Server Settings form
+	HttpSettingsCert string `json:"http_settings_cert"`
+	// HttpsWebUI is the HTTPS WebUI checkbox of the HTTP Server Settings form
+	HttpsWebUI string `json:"https_webui"`
+	// HttpsIPP is the HTTPS IPP checkbox of the HTTP Server Settings form
+	HttpsIPP string `json:"https_ipp"`
+}
+
+// ProfileWaits are how long to wait for the printer
+type ProfileWaits struct {
+	// ProcessingSeconds is the wait after a cert is uploaded or deleted
+	ProcessingSeconds int `json:"processing_seconds"`
+	// RebootSeconds is the wait after a settings change restarts the printer
+	RebootSeconds int `json:"reboot_seconds"`
+}
+
+// defaultProfile is the profile of the models this tool was built for. It is
+// also used for models that don't match any profile.
+var defaultProfile = Profile{
+	Name:   "default",
+	Models: []string{`(?i)MFC-L2710DW`, `(?i)MFC-L2750DW`},
+	URLs: ProfileURLs{
+		Login:            "/general/status.html",
+		Information:      "/general/information.html",
+		NetworkStatus:    "/net/net/net_status.html",
+		CertList:         "/net/security/certificate/certificate.html",
+		CertView:         "/net/security/certificate/view.html",
+		CertImport:       "/net/security/certificate/import.html",
+		CertDelete:       "/net/security/certificate/delete.html",
+		CACertList:       "/net/security/certificate/ca_certificate.html",
+		CACertView:       "/net/security/certificate/ca_view.html",
+		CACertImport:     "/net/security/certificate/ca_import.html",
+		HttpSettings:     "net/net/certificate/http.html",
+		TLSSettings:      "/net/security/tls/tls.html",
+		ProtocolSettings: "/net/net/protocol.html",
+		SNMPSettings:     "/net/net/snmp.html",
+		AdminPassword:    "/admin/password.html",
+	},
+	PageIDs: ProfilePageIDs{
+		CertImport:   "390",
+		CertDelete:   "383",
+		HttpSettings: "326",
+	},
+	Fields: ProfileFields{
+		CertImportFile:   "B820",
+		HttpSettingsCert: "B903",
+		HttpsWebUI:       "B86c",
+		HttpsIPP:         "B87e",
+	},
+	Waits: ProfileWaits{
+		ProcessingSeconds: 10,
+		RebootSeconds:     60,
+	},
+}
+
+// builtinProfiles are checked in order for a model match
+var builtinProfiles = []Profile{
+	defaultProfile,
+}
+
+// registeredProfiles are the profiles added with RegisterProfile, which are
+// checked for a model match before the built-in profiles
+var (
+	registeredProfilesMu sync.RWMutex
+	registeredProfiles   []Profile
+)
+
+// RegisterProfile adds profile (e.g. from LoadProfileFile) to the profiles
+// that are matched against the detected model, so that it is used for every
+// printer of its Models (a profile without Models never matches). Registered
+// profiles are checked before the built-in ones, in the order they were
+// registered. A profile with the same Name as a registered one replaces it.
+func RegisterProfile(profile Profile) {
+	registeredProfilesMu.Lock()
+	defer registeredProfilesMu.Unlock()
+
+	for i := range registeredProfiles {
+		if registeredProfiles[i].Name == profile.Name {
+			registeredProfiles[i] = profile
+			return
+		}
+	}
+
+	registeredProfiles = append(registeredProfiles, profile)
+}
+
+// DefaultProfile returns the profile used for models that don't match any
+// built-in profile
+func DefaultProfile() Profile {
+	return defaultProfile
+}
+
+// matchesModel returns true if any of the profile's Models matches model
+func (profile Profile) matchesModel(model string) bool {
+	for _, pattern := range profile.Models {
+		matched, err := regexp.MatchString(pattern, model)
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProfileForModel returns the first registered or built-in profile that
+// matches model, or the default profile if none do
+func ProfileForModel(model string) Profile {
+	registeredProfilesMu.RLock()
+	defer registeredProfilesMu.RUnlock()
+
+	for _, profile := range registeredProfiles {
+		if profile.matchesModel(model) {
+			return profile
+		}
+	}
+
+	for _, profile := range builtinProfiles {
+		if profile.matchesModel(model) {
+			return profile
+		}
+	}
+
+	return defaultProfile
+}
+
+// LoadProfileFile reads a profile from the json file at path. Anything not
+// specified in the file is the same as the default profile.
+func LoadProfileFile(path string) (*Profile, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("printer: failed to read profile file (%w)", err)
+	}
+
+	// start from a copy of default (slices are replaced, not merged)
+	profile := defaultProfile
+	profile.Name = path
+	profile.Models = nil
+
+	dec := json.NewDecoder(bytes.NewReader(fileBytes))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&profile)
+	if err != nil {
+		return nil, fmt.Errorf("printer: failed to parse profile file (%w)", err)
+	}
+
+	for _, pattern := range profile.Models {
+		_, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("printer: profile file model '%s' is invalid (%w)", pattern, err)
+		}
+	}
+
+	if profile.Waits.ProcessingSeconds < 0 || profile.Waits.RebootSeconds <= 0 {
+		return nil, errors.New("printer: profile file waits must be positive")
+	}
+
+	if profile.CertLimit < 0 {
+		return nil, errors.New("printer: profile file cert limit can't be negative")
+	}
+
+	return &profile, nil
+}
+
+// processingWait returns how long to wait for the printer to process an
+// upload or delete
+func (profile Profile) processingWait() time.Duration {
+	return time.Duration(profile.Waits.ProcessingSeconds) * time.Second
+}
+
+// rebootWait returns how long to wait for the printer to restart
+func (profile Profile) rebootWait() time.Duration {
+	return time.Duration(profile.Waits.RebootSeconds) * time.Second
+}
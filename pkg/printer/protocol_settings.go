@@ -0,0 +1,250 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	errProtocolFieldsNotFound  = errors.New("printer: protocol settings: protocol fields not found")
+	errSNMPModeNotFound        = errors.New("printer: snmp settings: snmp mode not found")
+	errProtocolSettingsRefused = errors.New("printer: protocol settings: printer refused the settings (they weren't changed)")
+
+	// e.g. `SNMP v1/v2c read-write access and v3 read-write access` or
+	// `SNMP v3 read-write access and v1/v2c read-only access`
+	regexSNMPv1v2cLabel = regexp.MustCompile(`(?i)v1\s*/\s*v2c`)
+)
+
+// GetProtocolSettings returns the protocols on the Protocol page and whether
+// each is enabled, keyed by the protocol's label (e.g. "Telnet", "FTP",
+// "Raw Port"). The labels depend on the model and the WebUI language.
+func (p *printer) GetProtocolSettings() (map[string]bool, error) {
+	bodyBytes, err := p.getPage(p.profile.URLs.ProtocolSettings, "protocol settings")
+	if err != nil {
+		return nil, err
+	}
+
+	labels := parseBodyForLabels(bodyBytes)
+
+	protocols := make(map[string]bool)
+	for _, input := range inputsOfType(parseBodyForInputs(bodyBytes), "checkbox") {
+		label := labels[input["id"]]
+		if label == "" {
+			continue
+		}
+
+		_, checked := input["checked"]
+		protocols[label] = checked
+	}
+
+	if len(protocols) <= 0 {
+		return nil, errProtocolFieldsNotFound
+	}
+
+	return protocols, nil
+}
+
+// GetSNMPv1v2cEnabled returns true if the selected SNMP mode on the SNMP page
+// allows (insecure) SNMP v1 or v2c access
+func (p *printer) GetSNMPv1v2cEnabled() (bool, error) {
+	bodyBytes, err := p.getPage(p.profile.URLs.SNMPSettings, "snmp settings")
+	if err != nil {
+		return false, err
+	}
+
+	labels := parseBodyForLabels(bodyBytes)
+
+	for _, input := range inputsOfType(parseBodyForInputs(bodyBytes), "radio") {
+		label := labels[input["id"]]
+		if !strings.Contains(strings.ToLower(label), "snmp") {
+			continue
+		}
+
+		_, checked := input["checked"]
+		if !checked {
+			continue
+		}
+
+		return regexSNMPv1v2cLabel.MatchString(label), nil
+	}
+
+	return false, errSNMPModeNotFound
+}
+
+// protocolCheckboxes returns the named protocol checkboxes (the ones with a
+// label) of form
+func protocolCheckboxes(form htmlForm) []htmlInput {
+	checkboxes := []htmlInput{}
+	for _, input := range inputsOfType(form.inputs, "checkbox") {
+		if input["name"] != "" && form.labels[input["id"]] != "" {
+			checkboxes = append(checkboxes, input)
+		}
+	}
+
+	return checkboxes
+}
+
+// protocolsMatch returns true if every protocol in protocols (case
+// insensitive label) has the same state in current
+func protocolsMatch(current, protocols map[string]bool) bool {
+	for protocol, enabled := range protocols {
+		found := false
+		for label, currEnabled := range current {
+			if strings.EqualFold(label, protocol) {
+				found = true
+				if currEnabled != enabled {
+					return false
+				}
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetProtocolSettings enables or disables the protocols on the Protocol page,
+// keyed by the protocol's label (case insensitive, see GetProtocolSettings).
+// Protocols that are not specified are left unchanged.
+//
+// If the printer asks to restart to activate the settings, restartAsked is
+// true and the restart is confirmed only if restart is true (the printer is
+// then restarting). Otherwise the settings are activated by the next restart
+// (e.g. ChangeHttpSettings), so the caller must check them after it. If the
+// printer doesn't ask, the settings are read back and it returns an error if
+// the printer didn't change them.
+func (p *printer) SetProtocolSettings(protocols map[string]bool, restart bool) (restartAsked bool, err error) {
+	bodyBytes, err := p.getPage(p.profile.URLs.ProtocolSettings, "protocol settings")
+	if err != nil {
+		return false, err
+	}
+
+	form := parseBodyForForm(bodyBytes, p.profile.URLs.ProtocolSettings)
+	labels := form.labels
+	checkboxes := protocolCheckboxes(form)
+
+	// start from the current form values so everything else is unchanged
+	// (this includes pageid and CSRFToken)
+	data := form.values()
+
+	for protocol, enabled := range protocols {
+		found := false
+		for _, input := range checkboxes {
+			if !strings.EqualFold(labels[input["id"]], protocol) {
+				continue
+			}
+
+			found = true
+			if enabled {
+				// checked checkbox value defaults to "on"
+				value, hasValue := input["value"]
+				if !hasValue {
+					value = "on"
+				}
+				data.Set(input["name"], value)
+			} else {
+				// unchecked checkboxes are not submitted
+				data.Del(input["name"])
+			}
+			break
+		}
+
+		if !found {
+			available := []string{}
+			for _, input := range checkboxes {
+				available = append(available, labels[input["id"]])
+			}
+			sort.Strings(available)
+
+			return false, fmt.Errorf("printer: protocol settings: protocol '%s' not found (protocols: %s)", protocol, strings.Join(available, ", "))
+		}
+	}
+
+	bodyBytes, err = p.postProtocolSettings(data, "post of protocol settings")
+	if err != nil {
+		return false, err
+	}
+
+	// the printer shows a confirmation (instead of the protocol form) if it
+	// needs to restart to activate the settings
+	confirmForm := parseBodyForForm(bodyBytes, p.profile.URLs.ProtocolSettings)
+	if len(protocolCheckboxes(confirmForm)) <= 0 {
+		csrfToken, err := parseBodyForCSRFToken(bodyBytes)
+		if err != nil {
+			return false, withPageMessages(errProtocolSettingsRefused, bodyBytes)
+		}
+
+		if !restart {
+			return true, nil
+		}
+
+		// submit confirmation (& reboot now), with the confirmation page's own
+		// hidden fields
+		data = confirmForm.values()
+		data.Set("CSRFToken", csrfToken)
+		_, err = p.postProtocolSettings(data, "post of protocol settings confirmation")
+		if err != nil {
+			return true, err
+		}
+
+		return true, nil
+	}
+
+	// the form was shown again, check the settings changed
+	current, err := p.GetProtocolSettings()
+	if err != nil {
+		return false, fmt.Errorf("printer: protocol settings: failed to read back settings (%w)", err)
+	}
+	if !protocolsMatch(current, protocols) {
+		return false, withPageMessages(errProtocolSettingsRefused, bodyBytes)
+	}
+
+	return false, nil
+}
+
+// postProtocolSettings posts data to the Protocol page and returns the
+// response page. op describes the post for errors.
+func (p *printer) postProtocolSettings(data url.Values, op string) ([]byte, error) {
+	// get url & set path
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = p.profile.URLs.ProtocolSettings
+
+	// make and do request
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// read body of response
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return nil, withPageMessages(newStatusError(op, resp), bodyBytes)
+	}
+
+	return bodyBytes, nil
+}
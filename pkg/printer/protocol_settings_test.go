@@ -0,0 +1,120 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// testProtocolPage returns a Protocol page with Telnet and FTP checked (or
+// not)
+func testProtocolPage(telnet, ftp bool) []byte {
+	checkbox := func(id, label string, checked bool) string {
+		state := ""
+		if checked {
+			state = " checked"
+		}
+		return fmt.Sprintf(`<dt><label for=%s>%s</label></dt><dd><input type=checkbox id=%s name=%s value=1%s></dd>`, id, label, id, id, state)
+	}
+
+	return []byte(`<html><body>` +
+		`<form method=post action="/general/status.html"><input type=hidden name=CSRFToken value=cHJvdG8=><input type=submit name=logout value=Logout></form>` +
+		`<form method=post action='protocol.html'>` +
+		`<input type=hidden name=CSRFToken value=cHJvdG8=><input type=hidden name=pageid value=18>` +
+		`<dl>` + checkbox("B1c0", "Telnet", telnet) + checkbox("B1c1", "FTP", ftp) + `</dl>` +
+		`<input type=submit value=Submit></form></body></html>`)
+}
+
+// testProtocolConfirmPage is the restart confirmation shown after the Protocol
+// page is submitted
+var testProtocolConfirmPage = []byte(`<html><body>` +
+	`<p class="confirmMsg">Restart the machine to activate the configuration?</p>` +
+	`<form method=post action='protocol.html'>` +
+	`<input type=hidden name=CSRFToken value=Y29uZmlybQ==><input type=hidden name=pageid value=18><input type=hidden name=protocol_mode value=1>` +
+	`<input type=submit name=B1d0 value=Yes></form></body></html>`)
+
+func TestSetProtocolSettings(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          bool
+		confirm         bool
+		restart         bool
+		wantAsked       bool
+		wantConfirmPost bool
+		wantErr         error
+	}{
+		{
+			name:   "accepted (form shown again)",
+			accept: true,
+		},
+		{
+			name:    "refused (form shown again, unchanged)",
+			accept:  false,
+			wantErr: errProtocolSettingsRefused,
+		},
+		{
+			name:      "restart asked, not confirmed",
+			accept:    true,
+			confirm:   true,
+			restart:   false,
+			wantAsked: true,
+		},
+		{
+			name:            "restart asked and confirmed",
+			accept:          true,
+			confirm:         true,
+			restart:         true,
+			wantAsked:       true,
+			wantConfirmPost: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telnet, ftp := true, true
+			confirmPosted := false
+			p := newTestPrinter(t, "password", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					_ = r.ParseForm()
+					if r.PostForm.Has("logout") {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+
+					// confirmation
+					if r.PostForm.Get("CSRFToken") == "Y29uZmlybQ==" {
+						confirmPosted = r.PostForm.Get("protocol_mode") == "1"
+						_, _ = w.Write(testProtocolPage(telnet, ftp))
+						return
+					}
+
+					if tt.accept {
+						telnet, ftp = r.PostForm.Has("B1c0"), r.PostForm.Has("B1c1")
+					}
+					if tt.confirm {
+						_, _ = w.Write(testProtocolConfirmPage)
+						return
+					}
+				}
+				_, _ = w.Write(testProtocolPage(telnet, ftp))
+			}))
+
+			asked, err := p.SetProtocolSettings(map[string]bool{"telnet": false}, tt.restart)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error is %v, want %v", err, tt.wantErr)
+			}
+			if asked != tt.wantAsked {
+				t.Errorf("restart asked is %t, want %t", asked, tt.wantAsked)
+			}
+			if confirmPosted != tt.wantConfirmPost {
+				t.Errorf("confirmation posted is %t, want %t", confirmPosted, tt.wantConfirmPost)
+			}
+
+			// ftp wasn't specified, so it is unchanged
+			if !ftp {
+				t.Errorf("ftp was changed")
+			}
+		})
+	}
+}
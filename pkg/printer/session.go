@@ -0,0 +1,335 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	errSessionLost       = errors.New("printer: session was lost (logged in again, but the change was not made)")
+	errLogoutNotFound    = errors.New("printer: logout: logout button not found")
+	regexLogoutControlID = regexp.MustCompile(`(?i)log\s*out`)
+)
+
+// do performs req. If the response shows the session was lost (e.g. the auth
+// cookie expired during a wait), it logs in again and retries req once if it
+// was a GET. Other requests can't be retried (their CSRFToken belonged to the
+// lost session), so errSessionLost is returned instead.
+func (p *printer) do(req *http.Request) (*http.Response, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, &UnreachableError{Hostname: p.hostname, Err: err}
+	}
+	if p.loginFieldName == "" {
+		return resp, nil
+	}
+
+	lost, err := p.sessionLost(req, resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if !lost {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	err = p.login(p.password)
+	if err != nil {
+		return nil, fmt.Errorf("printer: session was lost and login failed (%w)", err)
+	}
+
+	if req.Method != http.MethodGet {
+		return nil, errSessionLost
+	}
+
+	resp, err = p.httpClient.Do(req.Clone(req.Context()))
+	if err != nil {
+		return nil, &UnreachableError{Hostname: p.hostname, Err: err}
+	}
+
+	return resp, nil
+}
+
+// sessionLost returns true if resp is a redirect to, or a rendering of, the
+// login form (which only happens when not logged in). resp's body is read
+// and replaced so the caller can still read it.
+func (p *printer) sessionLost(req *http.Request, resp *http.Response) (bool, error) {
+	// the login page itself isn't a lost session
+	if req.URL.Path == p.profile.URLs.Login {
+		return false, nil
+	}
+
+	// redirect to login (only GETs, some POSTs normally redirect to the status
+	// page, which is also the login page)
+	if req.Method == http.MethodGet && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location, err := resp.Location()
+		if err == nil && location.Path == p.profile.URLs.Login {
+			return true, nil
+		}
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	// login form
+	for _, input := range inputsOfType(parseBodyForInputs(bodyBytes), "password") {
+		if input["name"] == p.loginFieldName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// loggedIn returns true if the printer's current session is logged in, which
+// is when the login page doesn't show the login form
+func (p *printer) loggedIn() (bool, error) {
+	bodyBytes, err := p.getPage(p.profile.URLs.Login, "login")
+	if err != nil {
+		return false, err
+	}
+
+	_, err = parsePasswordFieldName(bodyBytes)
+	return err != nil, nil
+}
+
+// isLogoutControl returns true if input is a logout button
+func isLogoutControl(input htmlInput) bool {
+	inputType := strings.ToLower(input["type"])
+	if inputType != "submit" && inputType != "button" && inputType != "image" {
+		return false
+	}
+
+	return input["name"] != "" && (regexLogoutControlID.MatchString(input["id"]) ||
+		regexLogoutControlID.MatchString(input["name"]) || regexLogoutControlID.MatchString(input["value"]))
+}
+
+// logout submits the logout button of the login (status) page
+func (p *printer) logout() error {
+	bodyBytes, err := p.getPage(p.profile.URLs.Login, "login")
+	if err != nil {
+		return err
+	}
+
+	// the logout form's own fields and the logout button
+	form := parseBodyForFormWith(bodyBytes, p.profile.URLs.Login, isLogoutControl)
+	data := form.values()
+
+	found := false
+	for _, input := range form.inputs {
+		if isLogoutControl(input) {
+			data.Set(input["name"], input["value"])
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errLogoutNotFound
+	}
+
+	// get url & set path
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return err
+	}
+	u.Path = p.profile.URLs.Login
+
+	// make and do request
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// read and discard entire body
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// Close ends the printer's session. If a session cache is in use, the
+// session is saved to it (so the next run doesn't need to log in) instead of
+// logging out. The printer shouldn't be used after Close.
+func (p *printer) Close() error {
+	if p == nil || p.loginFieldName == "" {
+		// never connected or logged in
+		return nil
+	}
+
+	var err error
+	if p.sessionCachePath != "" {
+		err = p.saveSession()
+	} else {
+		err = p.logout()
+	}
+
+	// forget the session either way
+	p.loginFieldName = ""
+	jar, jarErr := cookiejar.New(nil)
+	if jarErr == nil {
+		p.httpClient.Jar = jar
+	}
+
+	return err
+}
+
+// sessionCache is the json session cache file, which holds one session per
+// printer url
+type sessionCache struct {
+	Sessions map[string]cachedSession `json:"sessions"`
+}
+
+// cachedSession is a printer's saved session
+type cachedSession struct {
+	Cookies        []cachedCookie `json:"cookies"`
+	LoginFieldName string         `json:"login_field_name"`
+	Saved          time.Time      `json:"saved"`
+}
+
+// cachedCookie is one of a session's cookies
+type cachedCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// readSessionCache reads the session cache file at path. A file that doesn't
+// exist is an empty cache.
+func readSessionCache(path string) (*sessionCache, error) {
+	cache := &sessionCache{
+		Sessions: make(map[string]cachedSession),
+	}
+
+	fileBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("printer: failed to read session cache (%w)", err)
+	}
+
+	err = json.Unmarshal(fileBytes, cache)
+	if err != nil {
+		return nil, fmt.Errorf("printer: failed to parse session cache (%w)", err)
+	}
+	if cache.Sessions == nil {
+		cache.Sessions = make(map[string]cachedSession)
+	}
+
+	return cache, nil
+}
+
+// restoreSession loads the printer's session from the session cache and
+// returns true if it is still logged in
+func (p *printer) restoreSession() (bool, error) {
+	cache, err := readSessionCache(p.sessionCachePath)
+	if err != nil {
+		return false, err
+	}
+
+	session, exists := cache.Sessions[p.baseUrl]
+	if !exists || session.LoginFieldName == "" {
+		return false, nil
+	}
+
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return false, err
+	}
+
+	cookies := []*http.Cookie{}
+	for _, c := range session.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+	}
+	p.httpClient.Jar.SetCookies(u, cookies)
+
+	loggedIn, err := p.loggedIn()
+	if err != nil || !loggedIn {
+		// start over with no cookies
+		jar, jarErr := cookiejar.New(nil)
+		if jarErr == nil {
+			p.httpClient.Jar = jar
+		}
+		return false, err
+	}
+
+	p.loginFieldName = session.LoginFieldName
+	return true, nil
+}
+
+// saveSession saves the printer's session to the session cache. The file
+// contains credentials, so it is only readable by the owner.
+func (p *printer) saveSession() error {
+	cache, err := readSessionCache(p.sessionCachePath)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return err
+	}
+
+	session := cachedSession{
+		LoginFieldName: p.loginFieldName,
+		Saved:          time.Now(),
+	}
+	for _, c := range p.httpClient.Jar.Cookies(u) {
+		session.Cookies = append(session.Cookies, cachedCookie{Name: c.Name, Value: c.Value})
+	}
+	cache.Sessions[p.baseUrl] = session
+
+	fileBytes, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil {
+		return fmt.Errorf("printer: failed to encode session cache (%w)", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.sessionCachePath), 0700)
+	if err != nil {
+		return fmt.Errorf("printer: failed to save session cache (%w)", err)
+	}
+
+	// write to a temp file (created 0600) and rename, since WriteFile
+	// wouldn't fix the mode of an existing file that is readable by others
+	tmpFile, err := os.CreateTemp(filepath.Dir(p.sessionCachePath), ".brother-cert-sessions-*")
+	if err != nil {
+		return fmt.Errorf("printer: failed to save session cache (%w)", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(fileBytes)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("printer: failed to save session cache (%w)", err)
+	}
+
+	err = os.Rename(tmpFile.Name(), p.sessionCachePath)
+	if err != nil {
+		return fmt.Errorf("printer: failed to save session cache (%w)", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	errTLSVersionFieldsNotFound = errors.New("printer: tls settings: tls version fields not found")
+	errTLSSettingsRefused       = errors.New("printer: tls settings: printer refused the settings (they weren't changed)")
+
+	// e.g. `TLS1.2`, `TLS 1.2`, or `TLSv1.2`
+	regexTLSVersionLabel = regexp.MustCompile(`(?i)tls\s*v?\s*(1\.[0-3])`)
+
+	// e.g. `Cipher Strength` or `Encryption Strength`
+	regexCipherStrengthLabel = regexp.MustCompile(`(?i)cipher|encryption|strength`)
+)
+
+// TLSSettings are the settings on the TLS Settings page. Versions are in
+// the format "1.0", "1.1", "1.2", or "1.3".
+type TLSSettings struct {
+	// ServerMinVersion is the minimum version when the printer is the server
+	// (e.g. WebUI and IPPS)
+	ServerMinVersion string
+	// ClientMinVersion is the minimum version when the printer is the client
+	// (e.g. sending email or connecting to a server)
+	ClientMinVersion string
+	// CipherStrength is the label of the selected cipher strength option (e.g.
+	// "Strong"). Not all models have this option, in which case it is empty.
+	CipherStrength string
+}
+
+// tlsVersionSelects returns the version select elements on the TLS Settings
+// page, which are the selects with TLS version options. The first is the
+// server version and the second is the client version.
+func tlsVersionSelects(bodyBytes []byte) ([]htmlSelect, error) {
+	versionSelects := []htmlSelect{}
+	for _, sel := range parseBodyForSelects(bodyBytes) {
+		for _, opt := range sel.options {
+			if regexTLSVersionLabel.MatchString(opt.label) {
+				versionSelects = append(versionSelects, sel)
+				break
+			}
+		}
+	}
+
+	if len(versionSelects) != 2 {
+		return nil, errTLSVersionFieldsNotFound
+	}
+
+	return versionSelects, nil
+}
+
+// cipherStrengthSelect returns the cipher strength select element on the TLS
+// Settings page (page), which is the select labeled as such. found is false
+// if the model does not have this option.
+func cipherStrengthSelect(bodyBytes []byte, page string) (sel htmlSelect, found bool) {
+	return parseBodyForForm(bodyBytes, page).selectByLabel(regexCipherStrengthLabel)
+}
+
+// selectedOptionLabel returns the label of the selected option
+func selectedOptionLabel(sel htmlSelect) string {
+	selectedValue := sel.selectedValue()
+	for _, opt := range sel.options {
+		if opt.value == selectedValue {
+			return opt.label
+		}
+	}
+
+	return ""
+}
+
+// optionValueForLabel returns the value of the option with label (case
+// insensitive)
+func optionValueForLabel(sel htmlSelect, label string) (value string, found bool) {
+	for _, opt := range sel.options {
+		if strings.EqualFold(opt.label, label) {
+			return opt.value, true
+		}
+	}
+
+	return "", false
+}
+
+// selectedTLSVersion returns the version of the selected option
+func selectedTLSVersion(sel htmlSelect) string {
+	selectedValue := sel.selectedValue()
+	for _, opt := range sel.options {
+		if opt.value == selectedValue {
+			caps := regexTLSVersionLabel.FindStringSubmatch(opt.label)
+			if len(caps) == 2 {
+				return caps[1]
+			}
+		}
+	}
+
+	return ""
+}
+
+// tlsVersionOptionValue returns the option value to submit for version
+func tlsVersionOptionValue(sel htmlSelect, version string) (string, error) {
+	for _, opt := range sel.options {
+		caps := regexTLSVersionLabel.FindStringSubmatch(opt.label)
+		if len(caps) == 2 && caps[1] == version {
+			return opt.value, nil
+		}
+	}
+
+	return "", fmt.Errorf("printer: tls settings: version '%s' is not supported by the printer", version)
+}
+
+// getTLSSettingsPage fetches the TLS Settings page
+func (p *printer) getTLSSettingsPage() ([]byte, error) {
+	return p.getPage(p.profile.URLs.TLSSettings, "tls settings")
+}
+
+// GetTLSSettings returns the printer's current TLS settings
+func (p *printer) GetTLSSettings() (TLSSettings, error) {
+	bodyBytes, err := p.getTLSSettingsPage()
+	if err != nil {
+		return TLSSettings{}, err
+	}
+
+	versionSelects, err := tlsVersionSelects(bodyBytes)
+	if err != nil {
+		return TLSSettings{}, err
+	}
+
+	settings := TLSSettings{
+		ServerMinVersion: selectedTLSVersion(versionSelects[0]),
+		ClientMinVersion: selectedTLSVersion(versionSelects[1]),
+	}
+
+	strengthSelect, found := cipherStrengthSelect(bodyBytes, p.profile.URLs.TLSSettings)
+	if found {
+		settings.CipherStrength = selectedOptionLabel(strengthSelect)
+	}
+
+	return settings, nil
+}
+
+// SetTLSSettings updates the printer's TLS settings. Empty values are left
+// unchanged. The settings are read back afterwards, and it returns an error
+// if the printer didn't change them (e.g. it showed the form again with a
+// message).
+func (p *printer) SetTLSSettings(settings TLSSettings) error {
+	bodyBytes, err := p.getTLSSettingsPage()
+	if err != nil {
+		return err
+	}
+
+	versionSelects, err := tlsVersionSelects(bodyBytes)
+	if err != nil {
+		return err
+	}
+
+	// start from the current form values so everything else is unchanged
+	// (this includes pageid and CSRFToken)
+	data := parseBodyForFormValues(bodyBytes, p.profile.URLs.TLSSettings)
+
+	for i, version := range []string{settings.ServerMinVersion, settings.ClientMinVersion} {
+		if version == "" {
+			continue
+		}
+
+		value, err := tlsVersionOptionValue(versionSelects[i], version)
+		if err != nil {
+			return err
+		}
+
+		data.Set(versionSelects[i].attrs["name"], value)
+	}
+
+	if settings.CipherStrength != "" {
+		strengthSelect, found := cipherStrengthSelect(bodyBytes, p.profile.URLs.TLSSettings)
+		if !found {
+			return errors.New("printer: tls settings: cipher strength is not supported by the printer")
+		}
+
+		value, found := optionValueForLabel(strengthSelect, settings.CipherStrength)
+		if !found {
+			labels := []string{}
+			for _, opt := range strengthSelect.options {
+				labels = append(labels, opt.label)
+			}
+			return fmt.Errorf("printer: tls settings: cipher strength '%s' is not supported by the printer (options: %s)", settings.CipherStrength, strings.Join(labels, ", "))
+		}
+
+		data.Set(strengthSelect.attrs["name"], value)
+	}
+
+	// get url & set path
+	u, err := url.ParseRequestURI(p.baseUrl)
+	if err != nil {
+		return err
+	}
+	u.Path = p.profile.URLs.TLSSettings
+
+	// make and do request
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// read body of response
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// OK status?
+	if resp.StatusCode != http.StatusOK {
+		return withPageMessages(newStatusError("post of tls settings", resp), bodyBytes)
+	}
+
+	// the response may be the form whether or not the printer accepted the
+	// settings, so read them back
+	current, err := p.GetTLSSettings()
+	if err != nil {
+		return fmt.Errorf("printer: tls settings: failed to read back settings (%w)", err)
+	}
+
+	if (settings.ServerMinVersion != "" && current.ServerMinVersion != settings.ServerMinVersion) ||
+		(settings.ClientMinVersion != "" && current.ClientMinVersion != settings.ClientMinVersion) ||
+		(settings.CipherStrength != "" && !strings.EqualFold(current.CipherStrength, settings.CipherStrength)) {
+		return withPageMessages(errTLSSettingsRefused, bodyBytes)
+	}
+
+	return nil
+}
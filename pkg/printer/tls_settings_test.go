@@ -0,0 +1,80 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// testTLSPage returns a TLS Settings page with the selected option values
+func testTLSPage(server, client, strength string) []byte {
+	option := func(value, label, selected string) string {
+		if value == selected {
+			return fmt.Sprintf(`<option value='%s' selected>%s</option>`, value, label)
+		}
+		return fmt.Sprintf(`<option value='%s'>%s</option>`, value, label)
+	}
+
+	versions := func(name, selected string) string {
+		return fmt.Sprintf(`<select id=%s name=%s>%s%s%s</select>`, name, name,
+			option("1", "SSL3.0", selected), option("2", "TLS1.0", selected), option("4", "TLS 1.2", selected))
+	}
+
+	return []byte(`<html><body>` +
+		`<form method=post action="/general/status.html"><input type=hidden name=CSRFToken value=dGxz><input type=submit name=logout value=Logout></form>` +
+		`<form method=post action='tls.html'>` +
+		`<input type=hidden name=CSRFToken value=dGxz><input type=hidden name=pageid value=421>` +
+		`<dl><dt>Server</dt><dd>` + versions("B9a0", server) + `</dd>` +
+		`<dt>Client</dt><dd>` + versions("B9a1", client) + `</dd>` +
+		`<dt><label for=B9a2>Cipher Strength</label></dt><dd><select id=B9a2 name=B9a2>` +
+		option("0", "Weak", strength) + option("1", "Middle", strength) + option("2", "Strong", strength) +
+		`</select></dd></dl></form></body></html>`)
+}
+
+func TestSetTLSSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		accept  bool
+		wantErr error
+	}{
+		{
+			name:   "accepted",
+			accept: true,
+		},
+		{
+			name:    "refused (form shown again, unchanged)",
+			accept:  false,
+			wantErr: errTLSSettingsRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client, strength := "2", "1", "1"
+			p := newTestPrinter(t, "password", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					_ = r.ParseForm()
+					if r.PostForm.Get("CSRFToken") != "dGxz" || r.PostForm.Get("pageid") != "421" || r.PostForm.Has("logout") {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					if tt.accept {
+						server, client, strength = r.PostForm.Get("B9a0"), r.PostForm.Get("B9a1"), r.PostForm.Get("B9a2")
+					}
+				}
+				_, _ = w.Write(testTLSPage(server, client, strength))
+			}))
+
+			err := p.SetTLSSettings(TLSSettings{ServerMinVersion: "1.2", CipherStrength: "strong"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error is %v, want %v", err, tt.wantErr)
+			}
+
+			// the client version wasn't specified, so it is unchanged
+			if client != "1" {
+				t.Errorf("client version option is '%s', want it unchanged", client)
+			}
+		})
+	}
+}